@@ -2,35 +2,333 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/varsilias/zero-downtime/internal/jobs"
 	"github.com/varsilias/zero-downtime/internal/ollama"
 	"github.com/varsilias/zero-downtime/pkg/utils"
 	"net/http"
+	"net/url"
 )
 
-type Admin struct{ oc *ollama.Client }
+type Admin struct {
+	oc   ollama.API
+	jobs *jobs.Manager // nil when pulls run on another replica
+}
 
-func NewAdmin(oc *ollama.Client) *Admin { return &Admin{oc: oc} }
+// NewAdmin runs model pulls as background jobs, one at a time. Jobs live in this
+// process, so with several replicas only one should run them (pulls false elsewhere):
+// the pull and /admin/jobs routes of the others answer 421 Misdirected Request.
+func NewAdmin(oc ollama.API, pulls bool) *Admin {
+	a := &Admin{oc: oc}
+	if pulls {
+		a.jobs = jobs.NewManager(oc.Pull, 1)
+	}
+	return a
+}
+
+// runsJobs answers 421 when this replica does not run pull jobs.
+func (a *Admin) runsJobs(w http.ResponseWriter) bool {
+	if a.jobs == nil {
+		utils.JSON(w, http.StatusMisdirectedRequest, map[string]any{"error": "model pulls run on the admin replica (ADMIN_JOBS=false here)"})
+		return false
+	}
+	return true
+}
 
 // PullModel POST /admin/models/pull { name }
+// Starts a background pull and answers 202 with the job; follow it with
+// GET /admin/jobs/{id} or the SSE feed at /admin/jobs/{id}/events.
 func (a *Admin) PullModel(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	if !a.runsJobs(w) {
+		return
+	}
+	name, ok := decodeName(w, r)
+	if !ok {
+		return
+	}
+	job := a.jobs.Submit(name)
+	w.Header().Set("Location", "/admin/jobs/"+job.ID)
+	utils.JSON(w, http.StatusAccepted, job)
+}
+
+// modelName reads the model from the wildcard of /admin/models/*, so names with a
+// namespace (library/gemma3:270m) work unescaped.
+func modelName(r *http.Request) (string, error) {
+	name, err := url.PathUnescape(chi.URLParam(r, "*"))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ollama.ErrInvalidName, err)
+	}
+	return name, ollama.ValidName(name)
+}
+
+// ShowModel GET /admin/models/{name}
+// Family, parameter size, quantization, parameters, template and license of a local model.
+func (a *Admin) ShowModel(w http.ResponseWriter, r *http.Request) {
+	name, err := modelName(r)
+	if err != nil {
+		modelError(w, err)
+		return
+	}
+	info, err := a.oc.Show(r.Context(), name)
+	if err != nil {
+		modelError(w, err)
+		return
+	}
+	utils.JSON(w, http.StatusOK, info)
+}
+
+// DeleteModel DELETE /admin/models/{name}
+func (a *Admin) DeleteModel(w http.ResponseWriter, r *http.Request) {
+	name, err := modelName(r)
+	if err != nil {
+		modelError(w, err)
+		return
+	}
+	if err := a.oc.Delete(r.Context(), name); err != nil {
+		modelError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CopyModel POST /admin/models/copy { source, destination }
+// Tags an existing model under a second name; answers 201 with the new model's location.
+func (a *Admin) CopyModel(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Source      string `json:"source"`
+		Destination string `json:"destination"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.JSON(w, 400, map[string]any{"error": "invalid json"})
+		return
+	}
+	for _, name := range []string{req.Source, req.Destination} {
+		if err := ollama.ValidName(name); err != nil {
+			modelError(w, err)
+			return
+		}
+	}
+	if req.Source == req.Destination {
+		utils.JSON(w, 400, map[string]any{"error": "source and destination are the same"})
+		return
+	}
+	if err := a.oc.Copy(r.Context(), req.Source, req.Destination); err != nil {
+		modelError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/admin/models/"+req.Destination)
+	utils.JSON(w, http.StatusCreated, map[string]any{"name": req.Destination, "source": req.Source})
+}
+
+// CreateModel POST /admin/models/create { name, modelfile, quantize? }
+// Builds a model from a Modelfile whose FROM is a model Ollama already has; answers
+// 201 once Ollama is done.
+func (a *Admin) CreateModel(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name      string `json:"name"`
+		Modelfile string `json:"modelfile"`
+		Quantize  string `json:"quantize"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 2*ollama.MaxModelfile)).Decode(&req); err != nil {
+		utils.JSON(w, 400, map[string]any{"error": "invalid json"})
+		return
+	}
+	if err := ollama.ValidName(req.Name); err != nil {
+		modelError(w, err)
+		return
+	}
+	create, err := ollama.ParseModelfile(req.Name, req.Modelfile)
+	if err != nil {
+		modelError(w, err)
+		return
+	}
+	create.Quantize = req.Quantize
+	if err := a.oc.Create(r.Context(), create); err != nil {
+		modelError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/admin/models/"+req.Name)
+	utils.JSON(w, http.StatusCreated, map[string]any{"name": req.Name, "from": create.From})
+}
+
+// WarmupModel POST /admin/models/warmup { name }
+// Loads a model into memory (on every instance of a pool) without generating, so the
+// next prompt skips the load; answers once it is resident.
+func (a *Admin) WarmupModel(w http.ResponseWriter, r *http.Request) {
+	name, ok := decodeName(w, r)
+	if !ok {
+		return
+	}
+	took, err := a.oc.Load(r.Context(), name)
+	if err != nil {
+		modelError(w, err)
+		return
+	}
+	utils.JSON(w, http.StatusOK, map[string]any{"name": name, "load_ms": took.Milliseconds()})
+}
+
+// UnloadModel POST /admin/models/unload { name }
+// Evicts a model from memory now instead of when its keep_alive runs out.
+func (a *Admin) UnloadModel(w http.ResponseWriter, r *http.Request) {
+	name, ok := decodeName(w, r)
+	if !ok {
+		return
+	}
+	if err := a.oc.Unload(r.Context(), name); err != nil {
+		modelError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeName reads and validates a { name } body, answering 400 itself when it cannot.
+func decodeName(w http.ResponseWriter, r *http.Request) (string, bool) {
 	var req struct {
 		Name string `json:"name"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		utils.JSON(w, 400, map[string]any{"error": "invalid json"})
+		return "", false
+	}
+	if err := ollama.ValidName(req.Name); err != nil {
+		modelError(w, err)
+		return "", false
+	}
+	return req.Name, true
+}
+
+func modelError(w http.ResponseWriter, err error) {
+	var mfErr *ollama.ModelfileError
+	status := http.StatusBadGateway
+	switch {
+	case errors.Is(err, ollama.ErrInvalidName), errors.As(err, &mfErr):
+		status = http.StatusBadRequest
+	case errors.Is(err, ollama.ErrModelNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ollama.ErrCircuitOpen), errors.Is(err, ollama.ErrNoEndpoint):
+		status = http.StatusServiceUnavailable
+	}
+	utils.JSON(w, status, map[string]any{"error": err.Error()})
+}
+
+// ListJobs GET /admin/jobs
+func (a *Admin) ListJobs(w http.ResponseWriter, r *http.Request) {
+	if !a.runsJobs(w) {
+		return
+	}
+	utils.JSON(w, http.StatusOK, map[string]any{"jobs": a.jobs.List()})
+}
+
+// GetJob GET /admin/jobs/{id}
+func (a *Admin) GetJob(w http.ResponseWriter, r *http.Request) {
+	if !a.runsJobs(w) {
 		return
 	}
-	if req.Name == "" {
-		utils.JSON(w, 400, map[string]any{"error": "name required"})
+	job, err := a.jobs.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		jobError(w, err)
 		return
 	}
-	if err := a.oc.Pull(r.Context(), req.Name); err != nil {
-		utils.JSON(w, 500, map[string]any{"error": err.Error()})
+	utils.JSON(w, http.StatusOK, job)
+}
+
+// CancelJob DELETE /admin/jobs/{id}
+func (a *Admin) CancelJob(w http.ResponseWriter, r *http.Request) {
+	if !a.runsJobs(w) {
+		return
+	}
+	if err := a.jobs.Cancel(chi.URLParam(r, "id")); err != nil {
+		jobError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// JobEvents GET /admin/jobs/{id}/events
+// SSE feed: a "progress" event with the job on every change, then one "done",
+// "failed" or "canceled" event. Reconnecting starts with the current snapshot.
+func (a *Admin) JobEvents(w http.ResponseWriter, r *http.Request) {
+	if !a.runsJobs(w) {
+		return
+	}
+	job, updates, stop, err := a.jobs.Subscribe(chi.URLParam(r, "id"))
+	if err != nil {
+		jobError(w, err)
+		return
+	}
+	defer stop()
+	es := utils.NewEventStream(w)
+	for {
+		if job.State.Finished() {
+			_ = es.Send(string(job.State), job)
+			return
+		}
+		if err := es.Send("progress", job); err != nil {
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case next, ok := <-updates:
+			if !ok {
+				// closed right after the final snapshot was read; a job pruned since
+				// then has nothing more to report
+				if job, err = a.jobs.Get(job.ID); err != nil || !job.State.Finished() {
+					return
+				}
+				continue
+			}
+			job = next
+		}
+	}
+}
+
+func jobError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, jobs.ErrFinished):
+		status = http.StatusConflict
+	}
+	utils.JSON(w, status, map[string]any{"error": err.Error()})
+}
+
+// Circuits GET /admin/ollama/circuits
+// Reports the circuit breaker of every Ollama endpoint (closed, open or half-open).
+func (a *Admin) Circuits(w http.ResponseWriter, r *http.Request) {
+	utils.JSON(w, http.StatusOK, map[string]any{"circuits": a.oc.Circuits()})
+}
+
+// Loaded GET /admin/ollama/ps
+// Models resident in memory (from Ollama's /api/ps): memory use, VRAM share and when
+// each unloads if left idle. With a pool, every instance's models, each with its endpoint.
+func (a *Admin) Loaded(w http.ResponseWriter, r *http.Request) {
+	running, err := a.oc.PS(r.Context())
+	if err != nil {
+		modelError(w, err)
+		return
+	}
+	if running == nil {
+		running = []ollama.RunningModel{}
+	}
+	utils.JSON(w, http.StatusOK, map[string]any{"models": running})
+}
+
+// Endpoints GET /admin/ollama/endpoints
+// Reports every instance of a load-balanced Ollama pool: health, calls in flight and
+// loaded models. 404 when a single instance is configured.
+func (a *Admin) Endpoints(w http.ResponseWriter, r *http.Request) {
+	pool, ok := a.oc.(*ollama.Pool)
+	if !ok {
+		utils.JSON(w, http.StatusNotFound, map[string]any{"error": "ollama is not pooled (set several OLLAMA_BASE_URL entries)"})
 		return
 	}
-	utils.JSON(w, 200, map[string]any{"ok": true})
+	utils.JSON(w, http.StatusOK, map[string]any{"endpoints": pool.Endpoints()})
 }
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/varsilias/zero-downtime/internal/chat"
+	"github.com/varsilias/zero-downtime/internal/middleware"
+	"github.com/varsilias/zero-downtime/internal/session"
+	"github.com/varsilias/zero-downtime/pkg/types"
+	"github.com/varsilias/zero-downtime/pkg/utils"
+	"net/http"
+	"strings"
+)
+
+type branchRequest struct {
+	Model        string                `json:"model"`
+	Message      string                `json:"message"` // edited content (edit only)
+	Options      types.GenerateOptions `json:"options"`
+	GenerationID string                `json:"generation_id"`
+}
+
+// decodeBranchRequest reads and validates the body shared by regenerate and edit.
+func decodeBranchRequest(w http.ResponseWriter, r *http.Request) (branchRequest, bool) {
+	var req branchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.JSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+		return req, false
+	}
+	if req.Model == "" {
+		utils.JSON(w, http.StatusBadRequest, map[string]any{"error": "model is required"})
+		return req, false
+	}
+	if err := req.Options.Validate(); err != nil {
+		utils.JSON(w, http.StatusBadRequest, map[string]any{"error": "invalid options: " + err.Error()})
+		return req, false
+	}
+	if req.GenerationID == "" {
+		req.GenerationID, _ = r.Context().Value(middleware.RequestIDKey{}).(string)
+	}
+	w.Header().Set("X-Generation-ID", req.GenerationID)
+	return req, true
+}
+
+// branchError maps controller errors of the branching endpoints to HTTP statuses.
+func branchError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, session.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, chat.ErrNothingToRegenerate), errors.Is(err, chat.ErrNotUserMessage):
+		status = http.StatusBadRequest
+	case errors.Is(err, chat.ErrGenerationExists):
+		status = http.StatusConflict
+	case tooBusy(w, err):
+		status = http.StatusTooManyRequests
+	}
+	utils.JSON(w, status, map[string]any{"error": err.Error()})
+}
+
+// Regenerate POST /api/sessions/{id}/regenerate { model, options? }
+// Answers the last user message of the active branch again; the old answer becomes a sibling.
+func (h *Handlers) Regenerate(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeBranchRequest(w, r)
+	if !ok {
+		return
+	}
+	sessionID := chi.URLParam(r, "id")
+	ctx := chat.WithGenerationID(r.Context(), req.GenerationID)
+	msg, latency, err := h.chat.Regenerate(ctx, sessionID, req.Model, req.Options, nil)
+	if err != nil {
+		branchError(w, err)
+		return
+	}
+	writeReply(w, msg, latency, req.Model, sessionID, req.GenerationID)
+}
+
+// EditMessage POST /api/sessions/{id}/messages/{mid}/edit { model, message, options? }
+// Forks an edited copy of a user message and answers it on the new branch.
+func (h *Handlers) EditMessage(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeBranchRequest(w, r)
+	if !ok {
+		return
+	}
+	if strings.TrimSpace(req.Message) == "" {
+		utils.JSON(w, http.StatusBadRequest, map[string]any{"error": "message is required"})
+		return
+	}
+	sessionID := chi.URLParam(r, "id")
+	ctx := chat.WithGenerationID(r.Context(), req.GenerationID)
+	msg, latency, err := h.chat.Edit(ctx, sessionID, chi.URLParam(r, "mid"), req.Message, req.Model, req.Options, nil)
+	if err != nil {
+		branchError(w, err)
+		return
+	}
+	writeReply(w, msg, latency, req.Model, sessionID, req.GenerationID)
+}
+
+// Checkout POST /api/sessions/{id}/checkout { message_id }
+// Switches to the branch through message_id and returns the new active branch.
+func (h *Handlers) Checkout(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		MessageID string `json:"message_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MessageID == "" {
+		utils.JSON(w, http.StatusBadRequest, map[string]any{"error": "message_id required"})
+		return
+	}
+	branch, err := h.chat.SwitchBranch(chi.URLParam(r, "id"), req.MessageID)
+	if err != nil {
+		branchError(w, err)
+		return
+	}
+	utils.JSON(w, http.StatusOK, map[string]any{"history": branch})
+}
+
+// GetTree GET /api/sessions/{id}/tree returns every message with its parent ID.
+func (h *Handlers) GetTree(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "id")
+	all, err := h.sessions.Tree(sessionID)
+	if err != nil {
+		utils.JSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		return
+	}
+	branch, err := h.sessions.Get(sessionID)
+	if err != nil {
+		utils.JSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		return
+	}
+	head := ""
+	if n := len(branch); n > 0 {
+		head = branch[n-1].ID
+	}
+	utils.JSON(w, http.StatusOK, map[string]any{"session_id": sessionID, "head": head, "messages": all})
+}
@@ -2,13 +2,19 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
 	"github.com/varsilias/zero-downtime/internal/buildinfo"
 	"github.com/varsilias/zero-downtime/internal/chat"
+	"github.com/varsilias/zero-downtime/internal/middleware"
 	"github.com/varsilias/zero-downtime/internal/models"
 	"github.com/varsilias/zero-downtime/internal/session"
+	"github.com/varsilias/zero-downtime/pkg/types"
 	"github.com/varsilias/zero-downtime/pkg/utils"
 	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -30,13 +36,17 @@ func NewHandlers(log *slog.Logger, chatCtrl *chat.Controller, manager models.Man
 	}
 }
 
-// Health is a basic liveness endpoint.
+// Health is a basic liveness endpoint. It stays 200 while failed over to the fallback
+// engine; "backend" tells which one is serving.
 func (h *Handlers) Health(w http.ResponseWriter, r *http.Request) {
 	res := map[string]any{
 		"status":    true,
 		"message":   "zero-downtime-demo",
 		"timestamp": time.Now().UTC().Format(time.RFC3339),
 	}
+	if b, ok := h.chat.Backend(); ok {
+		res["backend"] = b
+	}
 	utils.JSON(w, http.StatusOK, res)
 }
 
@@ -51,12 +61,15 @@ func (h *Handlers) Version(w http.ResponseWriter, r *http.Request) {
 }
 
 // ListModels GET /api/models
+// Every model of the catalogue with the size, digest, family, parameter size and
+// quantization its backend reports.
 func (h *Handlers) ListModels(w http.ResponseWriter, r *http.Request) {
-	res := map[string]any{
-		"models": []string{"llama2", "mistral", "phi3"},
+	infos, err := h.models.List(r.Context())
+	if err != nil {
+		utils.JSON(w, http.StatusBadGateway, map[string]any{"error": err.Error()})
+		return
 	}
-	// TODO: query Model Manager / Ollama runtime
-	utils.JSON(w, http.StatusOK, res)
+	utils.JSON(w, http.StatusOK, map[string]any{"models": infos})
 }
 
 func (h *Handlers) Chat(w http.ResponseWriter, r *http.Request) {
@@ -65,9 +78,13 @@ func (h *Handlers) Chat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var req struct {
-		Model     string `json:"model"`
-		Message   string `json:"message"`
-		SessionID string `json:"session_id"`
+		Model     string                `json:"model"`
+		Message   string                `json:"message"`
+		SessionID string                `json:"session_id"`
+		Options   types.GenerateOptions `json:"options"`
+		// GenerationID lets the caller cancel the request via DELETE /api/chat/{id};
+		// it defaults to the request ID.
+		GenerationID string `json:"generation_id"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		utils.JSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
@@ -77,34 +94,78 @@ func (h *Handlers) Chat(w http.ResponseWriter, r *http.Request) {
 		utils.JSON(w, http.StatusBadRequest, map[string]any{"error": "model and message are required"})
 		return
 	}
+	if err := req.Options.Validate(); err != nil {
+		utils.JSON(w, http.StatusBadRequest, map[string]any{"error": "invalid options: " + err.Error()})
+		return
+	}
 	if req.SessionID == "" {
 		req.SessionID = "default"
 	}
 
-	msg, latency, err := h.chat.Chat(r.Context(), req.SessionID, req.Model, req.Message)
+	if req.GenerationID == "" {
+		req.GenerationID, _ = r.Context().Value(middleware.RequestIDKey{}).(string)
+	}
+	w.Header().Set("X-Generation-ID", req.GenerationID)
+
+	ctx := chat.WithGenerationID(r.Context(), req.GenerationID)
+	msg, latency, err := h.chat.Chat(ctx, req.SessionID, req.Model, req.Message, req.Options)
+	if errors.Is(err, chat.ErrGenerationExists) {
+		utils.JSON(w, http.StatusConflict, map[string]any{"error": err.Error()})
+		return
+	}
+	if tooBusy(w, err) {
+		utils.JSON(w, http.StatusTooManyRequests, map[string]any{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		utils.JSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
 		return
 	}
 
+	writeReply(w, msg, latency, req.Model, req.SessionID, req.GenerationID)
+}
+
+// tooBusy reports whether the scheduler shed the request (see chat.BusyError) and,
+// if so, sets Retry-After for the 429 the caller answers with.
+func tooBusy(w http.ResponseWriter, err error) bool {
+	var busy *chat.BusyError
+	if !errors.As(err, &busy) {
+		return false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(busy.RetryAfter.Seconds()))))
+	return true
+}
+
+// writeReply writes the JSON body shared by the endpoints that produce an assistant turn.
+func writeReply(w http.ResponseWriter, msg types.Message, latency time.Duration, model, sessionID, generationID string) {
 	utils.JSON(w, http.StatusOK, map[string]any{
-		"response":   msg.Content,
-		"timestamp":  msg.Timestamp.UTC().Format(time.RFC3339),
-		"latency_ms": latency.Milliseconds(),
-		"model":      req.Model,
-		"session_id": req.SessionID,
+		"response":      msg.Content,
+		"message_id":    msg.ID,
+		"timestamp":     msg.Timestamp.UTC().Format(time.RFC3339),
+		"latency_ms":    latency.Milliseconds(),
+		"model":         model,
+		"session_id":    sessionID,
+		"context":       msg.Context,
+		"stopped":       msg.Stopped,
+		"generation_id": generationID,
 	})
 }
 
-// GetHistory GET /api/history/:session_id
-func (h *Handlers) GetHistory(w http.ResponseWriter, r *http.Request) {
-	path := r.URL.Path
-	const prefix = "/api/history/"
-	if !strings.HasPrefix(path, prefix) {
-		http.NotFound(w, r)
+// CancelChat DELETE /api/chat/{id} stops an in-flight generation; the partial reply is
+// saved to the session marked as stopped.
+func (h *Handlers) CancelChat(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if !h.chat.Cancel(id) {
+		utils.JSON(w, http.StatusNotFound, map[string]any{"error": "no generation in flight with this id"})
 		return
 	}
-	sessionID := strings.TrimPrefix(path, prefix)
+	utils.JSON(w, http.StatusOK, map[string]any{"generation_id": id, "stopped": true})
+}
+
+// GetHistory GET /api/history/{session_id} returns the active branch. Messages with
+// other versions (regenerated or edited) report how many siblings they have.
+func (h *Handlers) GetHistory(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "session_id")
 	if sessionID == "" {
 		utils.JSON(w, http.StatusBadRequest, map[string]any{"error": "missing session_id"})
 		return
@@ -115,11 +176,71 @@ func (h *Handlers) GetHistory(w http.ResponseWriter, r *http.Request) {
 		utils.JSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
 		return
 	}
+	all, err := h.sessions.Tree(sessionID)
+	if err != nil {
+		utils.JSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		return
+	}
 
 	// Shape history for the contract
-	out := make([]map[string]string, 0, len(history))
+	out := make([]map[string]any, 0, len(history))
 	for _, m := range history {
-		out = append(out, map[string]string{"role": string(m.Role), "content": m.Content})
+		out = append(out, map[string]any{
+			"id":        m.ID,
+			"parent_id": m.ParentID,
+			"role":      string(m.Role),
+			"content":   m.Content,
+			"siblings":  len(session.Siblings(all, m.ID)),
+		})
 	}
 	utils.JSON(w, http.StatusOK, map[string]any{"history": out})
 }
+
+// ListPersonas GET /api/personas
+func (h *Handlers) ListPersonas(w http.ResponseWriter, r *http.Request) {
+	utils.JSON(w, http.StatusOK, map[string]any{"personas": chat.Personas})
+}
+
+// GetSystemPrompt GET /api/sessions/{id}/system
+func (h *Handlers) GetSystemPrompt(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "id")
+	prompt, err := h.sessions.SystemPrompt(sessionID)
+	if err != nil {
+		utils.JSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		return
+	}
+	utils.JSON(w, http.StatusOK, map[string]any{"session_id": sessionID, "system": prompt})
+}
+
+// SetSystemPrompt PUT /api/sessions/{id}/system { system } or { persona }
+// An empty body clears the prompt.
+func (h *Handlers) SetSystemPrompt(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		System  string `json:"system"`
+		Persona string `json:"persona"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.JSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+		return
+	}
+	prompt := strings.TrimSpace(req.System)
+	if req.Persona != "" {
+		p, ok := chat.PersonaByID(req.Persona)
+		if !ok {
+			utils.JSON(w, http.StatusBadRequest, map[string]any{"error": "unknown persona"})
+			return
+		}
+		prompt = p.Prompt
+	}
+	if len(prompt) > chat.MaxSystemPrompt {
+		utils.JSON(w, http.StatusBadRequest, map[string]any{"error": "system prompt too long"})
+		return
+	}
+
+	sessionID := chi.URLParam(r, "id")
+	if err := h.sessions.SetSystemPrompt(sessionID, prompt); err != nil {
+		utils.JSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		return
+	}
+	utils.JSON(w, http.StatusOK, map[string]any{"session_id": sessionID, "system": prompt})
+}
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/varsilias/zero-downtime/internal/chat"
+)
+
+func TestTooBusy(t *testing.T) {
+	w := httptest.NewRecorder()
+	busy := &chat.BusyError{Model: "m", Reason: "queue timeout", RetryAfter: 1500 * time.Millisecond}
+	if !tooBusy(w, fmt.Errorf("chat: %w", busy)) {
+		t.Fatal("a wrapped BusyError is not reported as busy")
+	}
+	if got := w.Header().Get("Retry-After"); got != "2" {
+		t.Fatalf("Retry-After = %q, want whole seconds rounded up", got)
+	}
+
+	w = httptest.NewRecorder()
+	if tooBusy(w, errors.New("engine down")) || w.Header().Get("Retry-After") != "" {
+		t.Fatal("an engine error is reported as busy")
+	}
+}
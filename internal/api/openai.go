@@ -0,0 +1,255 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/varsilias/zero-downtime/internal/chat"
+	"github.com/varsilias/zero-downtime/internal/middleware"
+	"github.com/varsilias/zero-downtime/pkg/types"
+	"github.com/varsilias/zero-downtime/pkg/utils"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OpenAI-compatible surface, so existing SDKs and CLIs can use this server as their
+// base URL (…/v1). Only what chat.Engine can honour is supported: one choice, text only.
+
+// SessionHeader names the session that a /v1/chat/completions exchange is recorded in.
+const SessionHeader = "X-Session-ID"
+
+type openAIMessage struct {
+	Role    string        `json:"role"`
+	Content openAIContent `json:"content"`
+}
+
+// openAIContent is a string or an array of content parts, of which text parts are kept.
+type openAIContent string
+
+func (c *openAIContent) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*c = openAIContent(s)
+		return nil
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(b, &parts); err != nil {
+		return errors.New("content must be a string or an array of content parts")
+	}
+	var texts []string
+	for _, p := range parts {
+		if p.Type != "text" {
+			return fmt.Errorf("unsupported content part %q", p.Type)
+		}
+		texts = append(texts, p.Text)
+	}
+	*c = openAIContent(strings.Join(texts, "\n"))
+	return nil
+}
+
+// openAIStop is a single stop sequence or an array of them.
+type openAIStop []string
+
+func (s *openAIStop) UnmarshalJSON(b []byte) error {
+	var one string
+	if err := json.Unmarshal(b, &one); err == nil {
+		*s = openAIStop{one}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]string)(s))
+}
+
+type completionRequest struct {
+	Model               string          `json:"model"`
+	Messages            []openAIMessage `json:"messages"`
+	Stream              bool            `json:"stream"`
+	Temperature         *float64        `json:"temperature"`
+	TopP                *float64        `json:"top_p"`
+	MaxTokens           *int            `json:"max_tokens"`
+	MaxCompletionTokens *int            `json:"max_completion_tokens"`
+	Seed                *int            `json:"seed"`
+	Stop                openAIStop      `json:"stop"`
+	N                   *int            `json:"n"`
+	StreamOptions       struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options"`
+}
+
+// options maps the OpenAI sampling fields onto ours.
+func (req completionRequest) options() types.GenerateOptions {
+	o := types.GenerateOptions{Temperature: req.Temperature, TopP: req.TopP, Seed: req.Seed, Stop: req.Stop}
+	o.NumPredict = req.MaxTokens
+	if req.MaxCompletionTokens != nil {
+		o.NumPredict = req.MaxCompletionTokens
+	}
+	return o
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// usage estimates token counts the way the context window does (see chat.EstimateTokens).
+func usage(prompt []types.Message, reply string) openAIUsage {
+	u := openAIUsage{CompletionTokens: chat.EstimateTokens(types.Message{Role: types.RoleAssistant, Content: reply})}
+	for _, m := range prompt {
+		u.PromptTokens += chat.EstimateTokens(m)
+	}
+	u.TotalTokens = u.PromptTokens + u.CompletionTokens
+	return u
+}
+
+// openAIError writes an error in the OpenAI shape.
+func openAIError(w http.ResponseWriter, status int, typ, msg string) {
+	utils.JSON(w, status, map[string]any{"error": map[string]any{"message": msg, "type": typ, "param": nil, "code": nil}})
+}
+
+// ChatCompletions POST /v1/chat/completions
+// Streams SSE "data:" chunks ending with "data: [DONE]" when stream is true. The
+// X-Session-ID header records the exchange in that session; the response carries
+// X-Generation-ID, which DELETE /api/chat/{id} accepts to stop the generation.
+func (h *Handlers) ChatCompletions(w http.ResponseWriter, r *http.Request) {
+	var req completionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		openAIError(w, http.StatusBadRequest, "invalid_request_error", "invalid json: "+err.Error())
+		return
+	}
+	if req.Model == "" || len(req.Messages) == 0 {
+		openAIError(w, http.StatusBadRequest, "invalid_request_error", "model and messages are required")
+		return
+	}
+	if req.N != nil && *req.N != 1 {
+		openAIError(w, http.StatusBadRequest, "invalid_request_error", "only n=1 is supported")
+		return
+	}
+	msgs := make([]types.Message, 0, len(req.Messages))
+	for i, m := range req.Messages {
+		role := types.Role(m.Role)
+		if m.Role == "developer" {
+			role = types.RoleSystem
+		}
+		if role != types.RoleSystem && role != types.RoleUser && role != types.RoleAssistant {
+			openAIError(w, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("messages[%d]: unsupported role %q", i, m.Role))
+			return
+		}
+		msgs = append(msgs, types.Message{Role: role, Content: string(m.Content), Timestamp: time.Now()})
+	}
+	opts := req.options()
+	if err := opts.Validate(); err != nil {
+		openAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	genID, _ := r.Context().Value(middleware.RequestIDKey{}).(string)
+	w.Header().Set("X-Generation-ID", genID)
+	ctx := chat.WithGenerationID(r.Context(), genID)
+	sessionID := r.Header.Get(SessionHeader)
+	id, created := "chatcmpl-"+genID, time.Now().Unix()
+
+	if !req.Stream {
+		msg, _, err := h.chat.Complete(ctx, sessionID, req.Model, msgs, opts, nil)
+		if err != nil {
+			openAIError(w, completionStatus(w, err), "server_error", err.Error())
+			return
+		}
+		utils.JSON(w, http.StatusOK, map[string]any{
+			"id":      id,
+			"object":  "chat.completion",
+			"created": created,
+			"model":   req.Model,
+			"choices": []map[string]any{{
+				"index":         0,
+				"message":       map[string]any{"role": "assistant", "content": msg.Content},
+				"finish_reason": "stop",
+			}},
+			"usage": usage(msgs, msg.Content),
+		})
+		return
+	}
+
+	// the stream starts with the first token, so errors before it get a proper status
+	var es *utils.EventStream
+	chunk := func(delta map[string]any, finish any) error {
+		if es == nil {
+			es = utils.NewEventStream(w)
+			// OpenAI opens every stream with the assistant role
+			if err := es.Send("", completionChunk(id, created, req.Model, map[string]any{"role": "assistant", "content": ""}, nil)); err != nil {
+				return err
+			}
+		}
+		return es.Send("", completionChunk(id, created, req.Model, delta, finish))
+	}
+	msg, _, err := h.chat.Complete(ctx, sessionID, req.Model, msgs, opts, func(token string) error {
+		return chunk(map[string]any{"content": token}, nil)
+	})
+	if err != nil {
+		if es == nil {
+			openAIError(w, completionStatus(w, err), "server_error", err.Error())
+			return
+		}
+		// headers are gone: report the failure in-band, as OpenAI does
+		_ = es.Send("", map[string]any{"error": map[string]any{"message": err.Error(), "type": "server_error"}})
+		return
+	}
+	if err := chunk(map[string]any{}, "stop"); err != nil {
+		return
+	}
+	if req.StreamOptions.IncludeUsage {
+		_ = es.Send("", map[string]any{
+			"id": id, "object": "chat.completion.chunk", "created": created, "model": req.Model,
+			"choices": []any{}, "usage": usage(msgs, msg.Content),
+		})
+	}
+	_ = es.SendRaw("", "[DONE]")
+}
+
+func completionChunk(id string, created int64, model string, delta map[string]any, finish any) map[string]any {
+	return map[string]any{
+		"id":      id,
+		"object":  "chat.completion.chunk",
+		"created": created,
+		"model":   model,
+		"choices": []map[string]any{{"index": 0, "delta": delta, "finish_reason": finish}},
+	}
+}
+
+func completionStatus(w http.ResponseWriter, err error) int {
+	switch {
+	case errors.Is(err, chat.ErrGenerationExists):
+		return http.StatusConflict
+	case errors.Is(err, chat.ErrNoRoute):
+		return http.StatusNotFound
+	case tooBusy(w, err):
+		return http.StatusTooManyRequests
+	}
+	return http.StatusInternalServerError
+}
+
+// ListOpenAIModels GET /v1/models
+// owned_by names the backend serving the model when several are routed.
+func (h *Handlers) ListOpenAIModels(w http.ResponseWriter, r *http.Request) {
+	infos, err := h.models.List(r.Context())
+	if err != nil {
+		openAIError(w, http.StatusBadGateway, "server_error", err.Error())
+		return
+	}
+	data := make([]map[string]any, 0, len(infos))
+	for _, m := range infos {
+		owner := m.Backend
+		if owner == "" {
+			owner = "ollama"
+		}
+		var created int64
+		if m.ModifiedAt != nil {
+			created = m.ModifiedAt.Unix()
+		}
+		data = append(data, map[string]any{"id": m.Name, "object": "model", "created": created, "owned_by": owner})
+	}
+	utils.JSON(w, http.StatusOK, map[string]any{"object": "list", "data": data})
+}
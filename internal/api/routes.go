@@ -7,10 +7,42 @@ func RegisterRoutes(mux *chi.Mux, h *Handlers) {
 	mux.Get("/version", h.Version)
 
 	mux.Post("/api/chat", h.Chat)
+	mux.Delete("/api/chat/{id}", h.CancelChat)
 	mux.Get("/api/models", h.ListModels)
+	mux.Get("/api/personas", h.ListPersonas)
+	mux.Get("/api/search", h.Search)
+	mux.Get("/api/sessions", h.ListSessions)
+	mux.Post("/api/sessions", h.CreateSession)
+	mux.Post("/api/sessions/import", h.ImportSession)
+	mux.Get("/api/sessions/{id}", h.GetSession)
+	mux.Patch("/api/sessions/{id}", h.UpdateSession)
+	mux.Delete("/api/sessions/{id}", h.DeleteSession)
+	mux.Get("/api/sessions/{id}/system", h.GetSystemPrompt)
+	mux.Put("/api/sessions/{id}/system", h.SetSystemPrompt)
+	mux.Get("/api/sessions/{id}/export", h.ExportSession)
 
-	mux.Get("/api/history/", h.GetHistory)
+	mux.Post("/v1/chat/completions", h.ChatCompletions)
+	mux.Get("/v1/models", h.ListOpenAIModels)
+
+	mux.Get("/api/history/{session_id}", h.GetHistory)
+	mux.Get("/api/sessions/{id}/tree", h.GetTree)
+	mux.Post("/api/sessions/{id}/regenerate", h.Regenerate)
+	mux.Post("/api/sessions/{id}/messages/{mid}/edit", h.EditMessage)
+	mux.Post("/api/sessions/{id}/checkout", h.Checkout)
 	if h.Admin != nil {
 		mux.Post("/admin/models/pull", h.Admin.PullModel)
+		mux.Post("/admin/models/copy", h.Admin.CopyModel)
+		mux.Post("/admin/models/create", h.Admin.CreateModel)
+		mux.Post("/admin/models/warmup", h.Admin.WarmupModel)
+		mux.Post("/admin/models/unload", h.Admin.UnloadModel)
+		mux.Get("/admin/models/*", h.Admin.ShowModel)
+		mux.Delete("/admin/models/*", h.Admin.DeleteModel)
+		mux.Get("/admin/jobs", h.Admin.ListJobs)
+		mux.Get("/admin/jobs/{id}", h.Admin.GetJob)
+		mux.Delete("/admin/jobs/{id}", h.Admin.CancelJob)
+		mux.Get("/admin/jobs/{id}/events", h.Admin.JobEvents)
+		mux.Get("/admin/ollama/circuits", h.Admin.Circuits)
+		mux.Get("/admin/ollama/ps", h.Admin.Loaded)
+		mux.Get("/admin/ollama/endpoints", h.Admin.Endpoints)
 	}
 }
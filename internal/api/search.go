@@ -0,0 +1,24 @@
+package api
+
+import (
+	"github.com/varsilias/zero-downtime/internal/session"
+	"github.com/varsilias/zero-downtime/pkg/utils"
+	"net/http"
+	"strconv"
+)
+
+// Search GET /api/search?q=...&limit=20 finds messages across every session, newest first.
+func (h *Handlers) Search(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	if len(session.ParseQuery(q)) == 0 {
+		utils.JSON(w, http.StatusBadRequest, map[string]any{"error": "q must contain at least one word"})
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	hits, err := h.sessions.Search(q, limit)
+	if err != nil {
+		utils.JSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		return
+	}
+	utils.JSON(w, http.StatusOK, map[string]any{"query": q, "results": hits})
+}
@@ -0,0 +1,233 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/varsilias/zero-downtime/internal/chat"
+	"github.com/varsilias/zero-downtime/internal/session"
+	"github.com/varsilias/zero-downtime/pkg/types"
+	"github.com/varsilias/zero-downtime/pkg/utils"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// sessionError maps store errors of the session endpoints to HTTP statuses.
+func sessionError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, session.ErrSessionNotFound) {
+		status = http.StatusNotFound
+	} else if errors.Is(err, session.ErrPinLimit) {
+		status = http.StatusConflict
+	}
+	utils.JSON(w, status, map[string]any{"error": err.Error()})
+}
+
+func newSessionID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// validTitle trims a custom title and checks its length.
+func validTitle(w http.ResponseWriter, title string) (string, bool) {
+	title = strings.TrimSpace(title)
+	if len(title) > session.MaxTitle {
+		utils.JSON(w, http.StatusBadRequest, map[string]any{"error": "title too long"})
+		return "", false
+	}
+	return title, true
+}
+
+// ListSessions GET /api/sessions — pinned first, then most recently updated.
+func (h *Handlers) ListSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := h.sessions.List()
+	if err != nil {
+		sessionError(w, err)
+		return
+	}
+	utils.JSON(w, http.StatusOK, map[string]any{"sessions": sessions})
+}
+
+// CreateSession POST /api/sessions { id?, title? }
+// A missing id is generated; creating an existing session only applies the title.
+func (h *Handlers) CreateSession(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID    string `json:"id"`
+		Title string `json:"title"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.JSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+			return
+		}
+	}
+	title, ok := validTitle(w, req.Title)
+	if !ok {
+		return
+	}
+	if req.ID == "" {
+		req.ID = newSessionID()
+	}
+	if err := h.sessions.Create(req.ID); err != nil {
+		sessionError(w, err)
+		return
+	}
+	if title != "" {
+		if err := h.sessions.Rename(req.ID, title); err != nil {
+			sessionError(w, err)
+			return
+		}
+	}
+	sum, err := h.sessions.Meta(req.ID)
+	if err != nil {
+		sessionError(w, err)
+		return
+	}
+	utils.JSON(w, http.StatusCreated, sum)
+}
+
+// GetSession GET /api/sessions/{id}
+func (h *Handlers) GetSession(w http.ResponseWriter, r *http.Request) {
+	sum, err := h.sessions.Meta(chi.URLParam(r, "id"))
+	if err != nil {
+		sessionError(w, err)
+		return
+	}
+	utils.JSON(w, http.StatusOK, sum)
+}
+
+// UpdateSession PATCH /api/sessions/{id} { title?, pinned? }
+// An empty title reverts to the one derived from the first message.
+func (h *Handlers) UpdateSession(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Title  *string `json:"title"`
+		Pinned *bool   `json:"pinned"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.JSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+		return
+	}
+	sessionID := chi.URLParam(r, "id")
+	if req.Title != nil {
+		title, ok := validTitle(w, *req.Title)
+		if !ok {
+			return
+		}
+		if err := h.sessions.Rename(sessionID, title); err != nil {
+			sessionError(w, err)
+			return
+		}
+	}
+	if req.Pinned != nil {
+		if err := h.sessions.Pin(sessionID, *req.Pinned); err != nil {
+			sessionError(w, err)
+			return
+		}
+	}
+	h.GetSession(w, r)
+}
+
+// DeleteSession DELETE /api/sessions/{id} removes the session with every branch.
+func (h *Handlers) DeleteSession(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "id")
+	if err := h.sessions.Delete(sessionID); err != nil {
+		sessionError(w, err)
+		return
+	}
+	utils.JSON(w, http.StatusOK, map[string]any{"session_id": sessionID, "deleted": true})
+}
+
+// ExportSession GET /api/sessions/{id}/export?format=md|json|jsonl downloads the active
+// branch with the system prompt and per-message metadata.
+func (h *Handlers) ExportSession(w http.ResponseWriter, r *http.Request) {
+	format, err := session.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		utils.JSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	sessionID := chi.URLParam(r, "id")
+	sum, err := h.sessions.Meta(sessionID)
+	if err != nil {
+		sessionError(w, err)
+		return
+	}
+	msgs, err := h.sessions.Get(sessionID)
+	if err != nil {
+		sessionError(w, err)
+		return
+	}
+	system, err := h.sessions.SystemPrompt(sessionID)
+	if err != nil {
+		sessionError(w, err)
+		return
+	}
+	conv := session.Conversation{ID: sessionID, Title: sum.Title, System: system, ExportedAt: time.Now().UTC(), Messages: msgs}
+	if conv.Messages == nil {
+		conv.Messages = []types.Message{}
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "chat-" + sessionID + "." + string(format)}))
+	if err := session.Export(w, format, conv); err != nil {
+		h.log.Error("export session", "session", sessionID, "format", format, "err", err)
+	}
+}
+
+// ImportSession POST /api/sessions/import creates a new session from a conversation
+// file: our JSON/JSONL export or an OpenAI messages array, sent as the raw body or as
+// the "file" field of a multipart form.
+func (h *Handlers) ImportSession(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, session.MaxImportBytes)
+	data, err := readUpload(r)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			utils.JSON(w, http.StatusRequestEntityTooLarge, map[string]any{"error": "file too large"})
+			return
+		}
+		utils.JSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	conv, err := session.ParseConversation(data)
+	if err == nil && len(conv.System) > chat.MaxSystemPrompt {
+		err = errors.New("system prompt too long")
+	}
+	if err != nil {
+		utils.JSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	sessionID := newSessionID()
+	if err := session.Import(h.sessions, sessionID, conv); err != nil {
+		if errors.Is(err, session.ErrTooManyMessages) {
+			utils.JSON(w, http.StatusRequestEntityTooLarge, map[string]any{"error": err.Error()})
+			return
+		}
+		sessionError(w, err)
+		return
+	}
+	sum, err := h.sessions.Meta(sessionID)
+	if err != nil {
+		sessionError(w, err)
+		return
+	}
+	utils.JSON(w, http.StatusCreated, map[string]any{"session_id": sessionID, "session": sum})
+}
+
+// readUpload returns the "file" part of a multipart form, else the whole body.
+func readUpload(r *http.Request) ([]byte, error) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		f, _, err := r.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return io.ReadAll(f)
+	}
+	return io.ReadAll(r.Body)
+}
@@ -0,0 +1,106 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/varsilias/zero-downtime/internal/chat"
+	"github.com/varsilias/zero-downtime/internal/models"
+	"github.com/varsilias/zero-downtime/internal/ollama"
+	"github.com/varsilias/zero-downtime/internal/openai"
+	"github.com/varsilias/zero-downtime/internal/routing"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+)
+
+// Config is the multi-backend routing file (BACKENDS_FILE), e.g.
+//
+//	{
+//	  "backends": {
+//	    "ollama-a": {"type": "ollama", "url": "http://ollama-a:11434"},
+//	    "vllm":     {"type": "openai", "url": "http://vllm:8000/v1", "api_key": "${VLLM_API_KEY}"}
+//	  },
+//	  "routes": [{"match": "gemma3:*", "backend": "ollama-a"}, {"match": "gpt-*", "backend": "vllm"}],
+//	  "default": "ollama-a"
+//	}
+//
+// ${VAR} references are expanded from the environment, so secrets can stay out of the file.
+type Config struct {
+	Backends map[string]Spec `json:"backends"`
+	Routes   []routing.Route `json:"routes"`
+	Default  string          `json:"default"`
+}
+
+// Spec describes one backend.
+type Spec struct {
+	Type    string            `json:"type"` // ollama|openai|echo
+	URL     string            `json:"url"`
+	APIKey  string            `json:"api_key"` // openai only
+	Headers map[string]string `json:"headers"` // openai only
+	Models  []string          `json:"models"`  // echo only: the models it pretends to serve
+}
+
+// Load reads and validates the config at path.
+func Load(path string) (Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	var cfg Config
+	dec := json.NewDecoder(bytes.NewReader([]byte(os.ExpandEnv(string(b)))))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(cfg.Backends) == 0 {
+		return Config{}, fmt.Errorf("%s: no backends defined", path)
+	}
+	for name, s := range cfg.Backends {
+		switch s.Type {
+		case "ollama", "openai":
+			if s.URL == "" {
+				return Config{}, fmt.Errorf("%s: backend %q: url is required", path, name)
+			}
+		case "echo":
+		default:
+			return Config{}, fmt.Errorf("%s: backend %q: unknown type %q (want ollama, openai or echo)", path, name, s.Type)
+		}
+	}
+	return cfg, nil
+}
+
+// Names returns the backend names, sorted.
+func (c Config) Names() []string {
+	out := make([]string, 0, len(c.Backends))
+	for name := range c.Backends {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Open builds the clients of every backend and the router engine and merged model
+// manager on top of them. res and keepAlive apply to every Ollama backend.
+func Open(cfg Config, log *slog.Logger, res ollama.Resilience, keepAlive ollama.KeepAlive) (*chat.Router, *models.RouterManager, error) {
+	table, err := routing.NewTable(cfg.Routes, cfg.Default, cfg.Names())
+	if err != nil {
+		return nil, nil, err
+	}
+	engines := map[string]chat.ChatEngine{}
+	managers := map[string]models.Manager{}
+	for name, s := range cfg.Backends {
+		switch s.Type {
+		case "ollama":
+			c := ollama.NewClient(s.URL, log.With("backend", name), res, keepAlive)
+			engines[name], managers[name] = chat.NewOllamaEngine(c), models.NewOllamaManager(c)
+		case "openai":
+			c := openai.NewClient(s.URL, s.APIKey, s.Headers, log.With("backend", name))
+			engines[name], managers[name] = chat.NewOpenAIEngine(c), models.NewOpenAIManager(c)
+		case "echo":
+			engines[name], managers[name] = chat.NewEchoEngine(30*time.Millisecond), models.NewStaticManager(s.Models)
+		}
+	}
+	return chat.NewRouter(table, engines), models.NewRouterManager(table, managers, log), nil
+}
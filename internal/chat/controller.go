@@ -2,39 +2,330 @@ package chat
 
 import (
 	"context"
+	"errors"
 	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/varsilias/zero-downtime/internal/session"
 	"github.com/varsilias/zero-downtime/pkg/types"
 )
 
+// ErrNothingToRegenerate is returned when the active branch has no user turn to answer again.
+var ErrNothingToRegenerate = errors.New("no user message to regenerate from")
+
+// ErrNotUserMessage is returned when editing a message that was not written by the user.
+var ErrNotUserMessage = errors.New("only user messages can be edited")
+
 type Controller struct {
 	log      *slog.Logger
 	eng      Engine
 	sessions session.Store
+	window   *ContextBuilder
+	defaults types.ModelOptions
+	gens     *generations
 }
 
-func NewController(log *slog.Logger, eng Engine, store session.Store) *Controller {
-	return &Controller{log: log, eng: eng, sessions: store}
+// NewController wires the chat flow. window may be nil to always send the full history;
+// defaults are per-model generation options that request options are layered on top of.
+func NewController(log *slog.Logger, eng Engine, store session.Store, window *ContextBuilder, defaults types.ModelOptions) *Controller {
+	return &Controller{log: log, eng: eng, sessions: store, window: window, defaults: defaults, gens: newGenerations()}
+}
+
+// Backend reports the backend currently serving generations, when the engine knows it
+// (see Failover).
+func (c *Controller) Backend() (BackendStatus, bool) {
+	eng := c.eng
+	for {
+		if se, ok := eng.(StatusEngine); ok {
+			return se.Status(), true
+		}
+		w, ok := eng.(interface{ Unwrap() Engine })
+		if !ok {
+			return BackendStatus{}, false
+		}
+		eng = w.Unwrap() // e.g. a Scheduler in front of a Failover
+	}
 }
 
 // Chat orchestrates a single turn: persist user msg, call engine, persist assistant reply.
-func (c *Controller) Chat(ctx context.Context, sessionID, model, prompt string) (types.Message, time.Duration, error) {
-	c.log.Info("chat", "calling engine with model", model)
-	user := types.Message{Role: types.RoleUser, Content: prompt, Timestamp: time.Now()}
-	if err := c.sessions.Append(sessionID, user); err != nil {
+// Tag ctx with WithGenerationID to make the turn cancellable through Cancel; a stopped
+// turn saves the partial reply marked as stopped and returns it without error.
+func (c *Controller) Chat(ctx context.Context, sessionID, model, prompt string, opts types.GenerateOptions) (types.Message, time.Duration, error) {
+	return c.ChatStream(ctx, sessionID, model, prompt, opts, nil)
+}
+
+// ChatStream is Chat with incremental output: onToken receives the reply as the engine
+// produces it. Engines without streaming support deliver the whole reply as one token.
+// The full assistant message is persisted once the stream ends.
+func (c *Controller) ChatStream(ctx context.Context, sessionID, model, prompt string, opts types.GenerateOptions, onToken TokenFunc) (types.Message, time.Duration, error) {
+	return c.turn(ctx, sessionID, model, opts, onToken, func() ([]types.Message, func(types.Message) error, error) {
+		user := types.Message{ID: session.NewMessageID(), Role: types.RoleUser, Content: prompt, Timestamp: time.Now()}
+		if err := c.sessions.Append(sessionID, user); err != nil {
+			return nil, nil, err
+		}
+		history, err := c.sessions.Get(sessionID)
+		return history, func(reply types.Message) error { return c.sessions.Append(sessionID, reply) }, err
+	})
+}
+
+// Regenerate answers the last user message of the active branch again. The previous
+// reply stays in the tree as a sibling of the new one; the active branch only moves
+// once the new reply is in, so a failed regeneration leaves it as it was.
+func (c *Controller) Regenerate(ctx context.Context, sessionID, model string, opts types.GenerateOptions, onToken TokenFunc) (types.Message, time.Duration, error) {
+	return c.turn(ctx, sessionID, model, opts, onToken, func() ([]types.Message, func(types.Message) error, error) {
+		branch, err := c.sessions.Get(sessionID)
+		if err != nil {
+			return nil, nil, err
+		}
+		for i := len(branch) - 1; i >= 0; i-- {
+			if branch[i].Role == types.RoleUser {
+				parentID := branch[i].ID
+				return branch[:i+1], func(reply types.Message) error { return c.sessions.Fork(sessionID, parentID, reply) }, nil
+			}
+		}
+		return nil, nil, ErrNothingToRegenerate
+	})
+}
+
+// Edit resends an edited copy of the user message messageID. The copy becomes a sibling
+// of the original, so the old branch remains reachable. Like Regenerate, nothing is
+// stored unless a reply comes back.
+func (c *Controller) Edit(ctx context.Context, sessionID, messageID, content, model string, opts types.GenerateOptions, onToken TokenFunc) (types.Message, time.Duration, error) {
+	return c.turn(ctx, sessionID, model, opts, onToken, func() ([]types.Message, func(types.Message) error, error) {
+		all, err := c.sessions.Tree(sessionID)
+		if err != nil {
+			return nil, nil, err
+		}
+		orig, ok := session.Find(all, messageID)
+		if !ok {
+			return nil, nil, session.ErrNotFound
+		}
+		if orig.Role != types.RoleUser {
+			return nil, nil, ErrNotUserMessage
+		}
+		user := types.Message{ID: session.NewMessageID(), Role: types.RoleUser, Content: content, Timestamp: time.Now(), ParentID: orig.ParentID}
+		history := append(session.Branch(all, orig.ParentID), user)
+		return history, func(reply types.Message) error {
+			if err := c.sessions.Fork(sessionID, orig.ParentID, user); err != nil {
+				return err
+			}
+			return c.sessions.Append(sessionID, reply)
+		}, nil
+	})
+}
+
+// SwitchBranch makes the branch through messageID active, following its most recent
+// descendants down to a leaf, and returns the new active branch.
+func (c *Controller) SwitchBranch(sessionID, messageID string) ([]types.Message, error) {
+	all, err := c.sessions.Tree(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := session.Find(all, messageID); !ok {
+		return nil, session.ErrNotFound
+	}
+	if err := c.sessions.Checkout(sessionID, session.LatestLeaf(all, messageID)); err != nil {
+		return nil, err
+	}
+	return c.sessions.Get(sessionID)
+}
+
+// Cancel stops the in-flight generation with the given ID and reports whether it existed.
+func (c *Controller) Cancel(generationID string) bool {
+	return c.gens.stop(generationID)
+}
+
+// Complete answers a conversation supplied by the caller (OpenAI style), which may
+// start with system messages; nothing is trimmed. With a sessionID the exchange is also
+// recorded: the whole conversation when the session is empty, else only the latest user
+// turn, followed by the reply.
+func (c *Controller) Complete(ctx context.Context, sessionID, model string, msgs []types.Message, opts types.GenerateOptions, onToken TokenFunc) (types.Message, time.Duration, error) {
+	opts = c.defaults.For(model).Merge(opts)
+	if err := opts.Validate(); err != nil {
+		return types.Message{}, 0, err
+	}
+	ctx, done, err := c.gens.track(ctx)
+	if err != nil {
+		return types.Message{}, 0, err
+	}
+	defer done()
+	c.log.Info("chat", "calling engine with model", model, "stream", onToken != nil, "messages", len(msgs))
+	assistant, latency, err := c.reply(ctx, model, onToken, func(collect TokenFunc) (string, time.Duration, error) {
+		return c.send(ctx, model, msgs, opts, collect)
+	})
+	if err != nil {
 		return types.Message{}, 0, err
 	}
+	if sessionID != "" {
+		if err := c.record(sessionID, msgs, assistant); err != nil {
+			return types.Message{}, 0, err
+		}
+	}
+	return assistant, latency, nil
+}
 
-	text, latency, err := c.eng.Generate(ctx, model, prompt)
+// record appends a Complete exchange to the active branch of sessionID.
+func (c *Controller) record(sessionID string, msgs []types.Message, reply types.Message) error {
+	existing, err := c.sessions.Get(sessionID)
 	if err != nil {
-		c.log.Error("engine call", "error from engine server", err.Error())
+		return err
+	}
+	var system []string
+	turns := make([]types.Message, 0, len(msgs))
+	for _, m := range msgs {
+		if m.Role == types.RoleSystem {
+			system = append(system, m.Content)
+			continue
+		}
+		turns = append(turns, m)
+	}
+	if len(existing) > 0 {
+		// earlier turns were recorded by previous calls
+		last := len(turns)
+		for i := len(turns) - 1; i >= 0; i-- {
+			if turns[i].Role == types.RoleUser {
+				last = i
+				break
+			}
+		}
+		turns = turns[last:]
+	} else if len(system) > 0 {
+		if err := c.sessions.SetSystemPrompt(sessionID, strings.Join(system, "\n\n")); err != nil {
+			return err
+		}
+	}
+	now := time.Now()
+	for _, m := range turns {
+		m.ID, m.ParentID, m.Timestamp = session.NewMessageID(), "", now
+		if err := c.sessions.Append(sessionID, m); err != nil {
+			return err
+		}
+	}
+	return c.sessions.Append(sessionID, reply)
+}
+
+// turn runs prepare, which returns the branch to answer (ending with a user message)
+// and how to store the reply, then generates a reply and stores it.
+func (c *Controller) turn(ctx context.Context, sessionID, model string, opts types.GenerateOptions, onToken TokenFunc, prepare func() ([]types.Message, func(types.Message) error, error)) (types.Message, time.Duration, error) {
+	opts = c.defaults.For(model).Merge(opts)
+	if err := opts.Validate(); err != nil {
 		return types.Message{}, 0, err
 	}
-	assistant := types.Message{Role: types.RoleAssistant, Content: text, Timestamp: time.Now()}
-	if err := c.sessions.Append(sessionID, assistant); err != nil {
+	ctx, done, err := c.gens.track(ctx)
+	if err != nil {
+		return types.Message{}, 0, err
+	}
+	defer done()
+	c.log.Info("chat", "calling engine with model", model, "stream", onToken != nil)
+	history, save, err := prepare()
+	if err != nil {
+		return types.Message{}, 0, err
+	}
+
+	var info *types.ContextInfo
+	assistant, latency, err := c.reply(ctx, model, onToken, func(collect TokenFunc) (string, time.Duration, error) {
+		text, latency, ctxInfo, err := c.generate(ctx, sessionID, model, history, opts, collect)
+		info = ctxInfo
+		return text, latency, err
+	})
+	if err != nil {
+		return types.Message{}, 0, err
+	}
+	assistant.Context = info
+	if err := save(assistant); err != nil {
 		return types.Message{}, 0, err
 	}
 	return assistant, latency, nil
 }
+
+// reply runs gen and wraps its output in an assistant message. The reply is collected
+// as it streams, so a generation stopped through Cancel keeps its partial output.
+func (c *Controller) reply(ctx context.Context, model string, onToken TokenFunc, gen func(TokenFunc) (string, time.Duration, error)) (types.Message, time.Duration, error) {
+	var partial strings.Builder
+	collect := func(token string) error {
+		partial.WriteString(token)
+		if onToken != nil {
+			return onToken(token)
+		}
+		return nil
+	}
+
+	start := time.Now()
+	text, latency, err := gen(collect)
+	stopped := false
+	if err != nil {
+		if !errors.Is(context.Cause(ctx), ErrStopped) {
+			c.log.Error("engine call", "error from engine server", err.Error())
+			return types.Message{}, 0, err
+		}
+		c.log.Info("generation stopped", "id", generationID(ctx), "chars", partial.Len())
+		text, stopped, latency = partial.String(), true, time.Since(start)
+	}
+	return types.Message{ID: session.NewMessageID(), Role: types.RoleAssistant, Content: text, Timestamp: time.Now(), Stopped: stopped,
+		Model: model, LatencyMS: latency.Milliseconds()}, latency, nil
+}
+
+// generate sends the active branch with the session's system prompt first. For a
+// ChatEngine the branch is trimmed to the model's context budget; the returned
+// ContextInfo is nil unless history was trimmed.
+func (c *Controller) generate(ctx context.Context, sessionID, model string, history []types.Message, opts types.GenerateOptions, onToken TokenFunc) (string, time.Duration, *types.ContextInfo, error) {
+	system, err := c.sessions.SystemPrompt(sessionID)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	var sys []types.Message
+	if system != "" {
+		sys = []types.Message{{Role: types.RoleSystem, Content: system}}
+	}
+
+	var info *types.ContextInfo
+	if _, ok := c.eng.(ChatEngine); ok && c.window != nil {
+		reserved := 0
+		for _, m := range sys {
+			reserved += EstimateTokens(m)
+		}
+		w := c.window.Build(ctx, sessionID, model, history, reserved)
+		if len(w.Dropped) > 0 {
+			c.log.Info("context trimmed", "session", sessionID, "model", model, "dropped", len(w.Dropped), "tokens", w.Tokens, "budget", w.Budget)
+			info = &types.ContextInfo{Dropped: w.Dropped, Summarized: w.Summary, Tokens: w.Tokens + reserved, Budget: w.Budget + reserved}
+		}
+		history = w.Messages
+	}
+
+	text, latency, err := c.send(ctx, model, append(sys, history...), opts, onToken)
+	return text, latency, info, err
+}
+
+// send picks the richest path the engine supports: the whole conversation for a
+// ChatEngine, otherwise only the latest message with leading system messages folded in.
+// Streaming engines are always streamed so that onToken sees partial output.
+func (c *Controller) send(ctx context.Context, model string, history []types.Message, opts types.GenerateOptions, onToken TokenFunc) (string, time.Duration, error) {
+	if ce, ok := c.eng.(ChatEngine); ok {
+		return ce.ChatStream(ctx, model, history, opts, onToken)
+	}
+
+	// single-turn engines only see the latest message, with the system prompt folded in
+	var system []string
+	for _, m := range history {
+		if m.Role != types.RoleSystem {
+			break
+		}
+		system = append(system, m.Content)
+	}
+	var prompt string
+	if n := len(history); n > len(system) {
+		prompt = history[n-1].Content
+	}
+	if len(system) > 0 {
+		prompt = strings.Join(append(system, prompt), "\n\n")
+	}
+	if se, ok := c.eng.(StreamEngine); ok {
+		return se.GenerateStream(ctx, model, prompt, opts, onToken)
+	}
+	text, latency, err := c.eng.Generate(ctx, model, prompt, opts)
+	if err == nil {
+		err = onToken(text)
+	}
+	return text, latency, err
+}
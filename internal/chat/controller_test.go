@@ -0,0 +1,96 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/varsilias/zero-downtime/internal/session"
+	"github.com/varsilias/zero-downtime/pkg/types"
+)
+
+var errEngine = errors.New("engine down")
+
+// replyEngine answers with a fixed reply, or fails when err is set.
+type replyEngine struct {
+	reply string
+	err   error
+}
+
+func (e *replyEngine) Generate(ctx context.Context, model, prompt string, opts types.GenerateOptions) (string, time.Duration, error) {
+	return e.reply, time.Millisecond, e.err
+}
+
+func discard() *slog.Logger { return slog.New(slog.NewTextHandler(io.Discard, nil)) }
+
+func contents(msgs []types.Message) []string {
+	out := make([]string, 0, len(msgs))
+	for _, m := range msgs {
+		out = append(out, m.Content)
+	}
+	return out
+}
+
+func sameContents(a, b []types.Message) bool {
+	ca, cb := contents(a), contents(b)
+	if len(ca) != len(cb) {
+		return false
+	}
+	for i := range ca {
+		if ca[i] != cb[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// TestFailedTurnKeepsBranch checks that a regenerate or edit whose generation fails
+// leaves the active branch as it was, and that a successful one moves it.
+func TestFailedTurnKeepsBranch(t *testing.T) {
+	ctx := context.Background()
+	store := session.NewMemoryStore(session.MemoryLimits{})
+	defer store.Close()
+	eng := &replyEngine{reply: "a1"}
+	c := NewController(discard(), eng, store, nil, nil)
+	if _, _, err := c.Chat(ctx, "s1", "m", "q1", types.GenerateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := store.Get("s1")
+	userID := before[0].ID
+
+	eng.err = errEngine
+	if _, _, err := c.Regenerate(ctx, "s1", "m", types.GenerateOptions{}, nil); !errors.Is(err, errEngine) {
+		t.Fatalf("Regenerate = %v, want the engine error", err)
+	}
+	if got, _ := store.Get("s1"); !sameContents(got, before) {
+		t.Fatalf("after failed Regenerate: Get = %v, want %v", contents(got), contents(before))
+	}
+	if _, _, err := c.Edit(ctx, "s1", userID, "q1 edited", "m", types.GenerateOptions{}, nil); !errors.Is(err, errEngine) {
+		t.Fatalf("Edit = %v, want the engine error", err)
+	}
+	if got, _ := store.Get("s1"); !sameContents(got, before) {
+		t.Fatalf("after failed Edit: Get = %v, want %v", contents(got), contents(before))
+	}
+	if all, _ := store.Tree("s1"); len(all) != 2 {
+		t.Fatalf("failed turns stored messages: Tree = %v", contents(all))
+	}
+
+	eng.reply, eng.err = "a2", nil
+	if _, _, err := c.Regenerate(ctx, "s1", "m", types.GenerateOptions{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := store.Get("s1")
+	if len(got) != 2 || got[1].Content != "a2" || got[1].ParentID != userID {
+		t.Fatalf("after Regenerate: Get = %v, want a2 under the same prompt", contents(got))
+	}
+	eng.reply = "a3"
+	if _, _, err := c.Edit(ctx, "s1", userID, "q1 edited", "m", types.GenerateOptions{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := store.Get("s1"); len(got) != 2 || got[0].Content != "q1 edited" || got[1].Content != "a3" {
+		t.Fatalf("after Edit: Get = %v", contents(got))
+	}
+}
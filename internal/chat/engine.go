@@ -3,11 +3,31 @@ package chat
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
+
+	"github.com/varsilias/zero-downtime/pkg/types"
 )
 
 type Engine interface {
-	Generate(ctx context.Context, model, prompt string) (text string, latency time.Duration, err error)
+	Generate(ctx context.Context, model, prompt string, opts types.GenerateOptions) (text string, latency time.Duration, err error)
+}
+
+// TokenFunc receives generated text as it arrives. Returning an error aborts the stream.
+type TokenFunc func(token string) error
+
+// StreamEngine is an Engine that can emit tokens while the reply is being generated.
+type StreamEngine interface {
+	Engine
+	GenerateStream(ctx context.Context, model, prompt string, opts types.GenerateOptions, onToken TokenFunc) (text string, latency time.Duration, err error)
+}
+
+// ChatEngine is a StreamEngine that is given the whole conversation rather than
+// only the latest prompt, so the model can see earlier turns.
+type ChatEngine interface {
+	StreamEngine
+	Chat(ctx context.Context, model string, history []types.Message, opts types.GenerateOptions) (text string, latency time.Duration, err error)
+	ChatStream(ctx context.Context, model string, history []types.Message, opts types.GenerateOptions, onToken TokenFunc) (text string, latency time.Duration, err error)
 }
 
 type EchoEngine struct {
@@ -16,7 +36,7 @@ type EchoEngine struct {
 
 func NewEchoEngine(minLatency time.Duration) *EchoEngine { return &EchoEngine{minLatency: minLatency} }
 
-func (e *EchoEngine) Generate(ctx context.Context, model, prompt string) (string, time.Duration, error) {
+func (e *EchoEngine) Generate(ctx context.Context, model, prompt string, opts types.GenerateOptions) (string, time.Duration, error) {
 	start := time.Now()
 	if e.minLatency > 0 {
 		time.Sleep(e.minLatency)
@@ -24,3 +44,58 @@ func (e *EchoEngine) Generate(ctx context.Context, model, prompt string) (string
 	text := fmt.Sprintf("(demo:%s) you said: %s", model, prompt)
 	return text, time.Since(start), nil
 }
+
+// GenerateStream emits the echo reply word by word, spreading minLatency across the words.
+func (e *EchoEngine) GenerateStream(ctx context.Context, model, prompt string, opts types.GenerateOptions, onToken TokenFunc) (string, time.Duration, error) {
+	return e.stream(ctx, fmt.Sprintf("(demo:%s) you said: %s", model, prompt), onToken)
+}
+
+// Chat echoes the latest user turn and reports how many turns the engine was given.
+func (e *EchoEngine) Chat(ctx context.Context, model string, history []types.Message, opts types.GenerateOptions) (string, time.Duration, error) {
+	start := time.Now()
+	if e.minLatency > 0 {
+		time.Sleep(e.minLatency)
+	}
+	return echoHistory(model, history), time.Since(start), nil
+}
+
+func (e *EchoEngine) ChatStream(ctx context.Context, model string, history []types.Message, opts types.GenerateOptions, onToken TokenFunc) (string, time.Duration, error) {
+	return e.stream(ctx, echoHistory(model, history), onToken)
+}
+
+func echoHistory(model string, history []types.Message) string {
+	var last string
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == types.RoleUser {
+			last = history[i].Content
+			break
+		}
+	}
+	persona := ""
+	if len(history) > 0 && history[0].Role == types.RoleSystem {
+		persona = ", with system prompt"
+	}
+	return fmt.Sprintf("(demo:%s, %d turns in context%s) you said: %s", model, len(history), persona, last)
+}
+
+func (e *EchoEngine) stream(ctx context.Context, text string, onToken TokenFunc) (string, time.Duration, error) {
+	start := time.Now()
+	words := strings.SplitAfter(text, " ")
+	delay := e.minLatency / time.Duration(len(words))
+
+	var sb strings.Builder
+	for _, w := range words {
+		select {
+		case <-ctx.Done():
+			return sb.String(), time.Since(start), ctx.Err()
+		case <-time.After(delay):
+		}
+		sb.WriteString(w)
+		if onToken != nil {
+			if err := onToken(w); err != nil {
+				return sb.String(), time.Since(start), err
+			}
+		}
+	}
+	return sb.String(), time.Since(start), nil
+}
@@ -0,0 +1,180 @@
+package chat
+
+import (
+	"context"
+	"github.com/varsilias/zero-downtime/internal/ollama"
+	"github.com/varsilias/zero-downtime/pkg/types"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// BackendStatus describes which backend an engine is currently serving from.
+type BackendStatus struct {
+	Active   string    `json:"active"`
+	Primary  string    `json:"primary"`
+	Failover bool      `json:"failover"` // true while the fallback serves requests
+	Since    time.Time `json:"since"`
+	Error    string    `json:"error,omitempty"` // why the primary is considered down
+}
+
+// StatusEngine is an Engine that can report its active backend (see Failover).
+type StatusEngine interface {
+	Engine
+	Status() BackendStatus
+}
+
+// Failover serves from a primary engine while its probe succeeds and from the fallback
+// otherwise. The probe runs in the background every interval, so the engine switches
+// back and forth as the primary (e.g. Ollama) goes away and returns. A request that
+// cannot reach the primary before its first token marks it down and is retried on the
+// fallback at once instead of waiting for the next probe.
+type Failover struct {
+	primary, fallback         ChatEngine
+	primaryName, fallbackName string
+	probe                     func(context.Context) error
+	log                       *slog.Logger
+
+	mu     sync.RWMutex
+	up     bool
+	since  time.Time
+	reason string
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewFailover probes the primary once, then keeps probing every interval (default 5s)
+// until Close.
+func NewFailover(primaryName string, primary ChatEngine, fallbackName string, fallback ChatEngine, probe func(context.Context) error, interval time.Duration, log *slog.Logger) *Failover {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	f := &Failover{
+		primary: primary, fallback: fallback,
+		primaryName: primaryName, fallbackName: fallbackName,
+		probe: probe, log: log,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	f.check(interval)
+	go f.monitor(interval)
+	return f
+}
+
+func (f *Failover) monitor(every time.Duration) {
+	defer close(f.done)
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-t.C:
+			f.check(every)
+		}
+	}
+}
+
+// check runs one probe, bounded by timeout, and records the result.
+func (f *Failover) check(timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	f.set(f.probe(ctx))
+}
+
+// set records the primary as up (err == nil) or down, logging transitions.
+func (f *Failover) set(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	up := err == nil
+	if err != nil {
+		f.reason = err.Error()
+	} else {
+		f.reason = ""
+	}
+	first := f.since.IsZero()
+	if up == f.up && !first {
+		return
+	}
+	f.up, f.since = up, time.Now()
+	switch {
+	case up && first:
+		f.log.Info("primary backend reachable", "backend", f.primaryName)
+	case up:
+		f.log.Info("primary backend up: switching back", "backend", f.primaryName)
+	default:
+		f.log.Warn("primary backend down: failing over", "backend", f.primaryName, "fallback", f.fallbackName, "err", err)
+	}
+}
+
+// PrimaryUp reports whether requests currently go to the primary.
+func (f *Failover) PrimaryUp() bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.up
+}
+
+func (f *Failover) Status() BackendStatus {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	s := BackendStatus{Active: f.primaryName, Primary: f.primaryName, Failover: !f.up, Since: f.since, Error: f.reason}
+	if !f.up {
+		s.Active = f.fallbackName
+	}
+	return s
+}
+
+// Close stops the background probe and waits for it to exit.
+func (f *Failover) Close() error {
+	f.closeOnce.Do(func() { close(f.stop) })
+	<-f.done
+	return nil
+}
+
+// call runs fn on the active engine. When the primary is unreachable and nothing has
+// been streamed yet, the primary is marked down and fn runs again on the fallback.
+func (f *Failover) call(ctx context.Context, onToken TokenFunc, fn func(e ChatEngine, onToken TokenFunc) (string, time.Duration, error)) (string, time.Duration, error) {
+	if !f.PrimaryUp() {
+		return fn(f.fallback, onToken)
+	}
+	streamed := false
+	tracked := onToken
+	if onToken != nil {
+		tracked = func(token string) error {
+			streamed = true
+			return onToken(token)
+		}
+	}
+	text, latency, err := fn(f.primary, tracked)
+	if err == nil || streamed || ctx.Err() != nil || !ollama.Unreachable(err) {
+		return text, latency, err
+	}
+	f.set(err)
+	return fn(f.fallback, onToken)
+}
+
+func (f *Failover) Generate(ctx context.Context, model, prompt string, opts types.GenerateOptions) (string, time.Duration, error) {
+	return f.call(ctx, nil, func(e ChatEngine, _ TokenFunc) (string, time.Duration, error) {
+		return e.Generate(ctx, model, prompt, opts)
+	})
+}
+
+func (f *Failover) GenerateStream(ctx context.Context, model, prompt string, opts types.GenerateOptions, onToken TokenFunc) (string, time.Duration, error) {
+	return f.call(ctx, onToken, func(e ChatEngine, onToken TokenFunc) (string, time.Duration, error) {
+		return e.GenerateStream(ctx, model, prompt, opts, onToken)
+	})
+}
+
+func (f *Failover) Chat(ctx context.Context, model string, history []types.Message, opts types.GenerateOptions) (string, time.Duration, error) {
+	return f.call(ctx, nil, func(e ChatEngine, _ TokenFunc) (string, time.Duration, error) {
+		return e.Chat(ctx, model, history, opts)
+	})
+}
+
+func (f *Failover) ChatStream(ctx context.Context, model string, history []types.Message, opts types.GenerateOptions, onToken TokenFunc) (string, time.Duration, error) {
+	return f.call(ctx, onToken, func(e ChatEngine, onToken TokenFunc) (string, time.Duration, error) {
+		return e.ChatStream(ctx, model, history, opts, onToken)
+	})
+}
@@ -0,0 +1,169 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/varsilias/zero-downtime/pkg/types"
+)
+
+// scriptEngine streams tokens, then returns err; it counts its calls.
+type scriptEngine struct {
+	name   string
+	tokens []string
+	err    error
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (e *scriptEngine) set(tokens []string, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.tokens, e.err = tokens, err
+}
+
+func (e *scriptEngine) called() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.calls
+}
+
+func (e *scriptEngine) reply(onToken TokenFunc) (string, time.Duration, error) {
+	e.mu.Lock()
+	e.calls++
+	tokens, err := e.tokens, e.err
+	e.mu.Unlock()
+	text := ""
+	for _, tok := range tokens {
+		text += tok
+		if onToken != nil {
+			if err := onToken(tok); err != nil {
+				return text, 0, err
+			}
+		}
+	}
+	if err != nil {
+		return text, 0, err
+	}
+	return e.name + ":" + text, time.Millisecond, nil
+}
+
+func (e *scriptEngine) Generate(ctx context.Context, model, prompt string, opts types.GenerateOptions) (string, time.Duration, error) {
+	return e.reply(nil)
+}
+
+func (e *scriptEngine) GenerateStream(ctx context.Context, model, prompt string, opts types.GenerateOptions, onToken TokenFunc) (string, time.Duration, error) {
+	return e.reply(onToken)
+}
+
+func (e *scriptEngine) Chat(ctx context.Context, model string, history []types.Message, opts types.GenerateOptions) (string, time.Duration, error) {
+	return e.reply(nil)
+}
+
+func (e *scriptEngine) ChatStream(ctx context.Context, model string, history []types.Message, opts types.GenerateOptions, onToken TokenFunc) (string, time.Duration, error) {
+	return e.reply(onToken)
+}
+
+// refused is what an HTTP engine returns when nothing listens on the backend's port.
+var refused = &url.Error{Op: "Post", URL: "http://ollama:11434/api/chat", Err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}}
+
+// newTestFailover returns a Failover over two fake engines whose probe always finds
+// the primary up and never runs again during a test.
+func newTestFailover(t *testing.T) (*Failover, *scriptEngine, *scriptEngine) {
+	t.Helper()
+	primary, fallback := &scriptEngine{name: "primary"}, &scriptEngine{name: "fallback"}
+	probe := func(context.Context) error { return nil }
+	f := NewFailover("ollama", primary, "echo", fallback, probe, time.Hour, discard())
+	t.Cleanup(func() { f.Close() })
+	return f, primary, fallback
+}
+
+func chatOnce(f *Failover, onToken TokenFunc) (string, error) {
+	text, _, err := f.ChatStream(context.Background(), "m", []types.Message{{Role: types.RoleUser, Content: "hi"}}, types.GenerateOptions{}, onToken)
+	return text, err
+}
+
+func TestFailoverUnreachablePrimary(t *testing.T) {
+	f, primary, fallback := newTestFailover(t)
+	primary.set(nil, refused)
+
+	text, err := chatOnce(f, nil)
+	if err != nil || text != "fallback:" {
+		t.Fatalf("ChatStream = %q, %v; want the fallback's reply", text, err)
+	}
+	if f.PrimaryUp() || !f.Status().Failover || f.Status().Active != "echo" {
+		t.Fatalf("Status = %+v, want failed over to echo", f.Status())
+	}
+	// while down, requests skip the primary altogether
+	if _, err := chatOnce(f, nil); err != nil || primary.called() != 1 || fallback.called() != 2 {
+		t.Fatalf("calls: primary %d, fallback %d (err %v)", primary.called(), fallback.called(), err)
+	}
+}
+
+func TestFailoverProbe(t *testing.T) {
+	primary, fallback := &scriptEngine{name: "primary"}, &scriptEngine{name: "fallback"}
+	var down atomic.Bool
+	down.Store(true)
+	probe := func(context.Context) error {
+		if down.Load() {
+			return refused
+		}
+		return nil
+	}
+	f := NewFailover("ollama", primary, "echo", fallback, probe, 10*time.Millisecond, discard())
+	defer f.Close()
+	if text, err := chatOnce(f, nil); err != nil || text != "fallback:" || primary.called() != 0 {
+		t.Fatalf("primary down at start: %q, %v", text, err)
+	}
+
+	// the background probe switches back once the primary answers again
+	down.Store(false)
+	waitFor(t, "switch back", f.PrimaryUp)
+	if text, err := chatOnce(f, nil); err != nil || text != "primary:" {
+		t.Fatalf("after recovery: %q, %v", text, err)
+	}
+}
+
+func TestFailoverKeepsPrimaryOnBackendErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		err  error
+	}{
+		{"unknown model", errors.New("ollama chat: 404 model not found")},
+		{"bad request", errors.New("openai chat: 400 Bad Request: invalid temperature")},
+		{"timeout", context.DeadlineExceeded},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f, primary, fallback := newTestFailover(t)
+			primary.set(nil, tc.err)
+			if _, err := chatOnce(f, nil); !errors.Is(err, tc.err) {
+				t.Fatalf("ChatStream = %v, want the primary's error", err)
+			}
+			if !f.PrimaryUp() || fallback.called() != 0 {
+				t.Fatalf("failed over on %v (fallback calls %d)", tc.err, fallback.called())
+			}
+		})
+	}
+}
+
+func TestFailoverAfterTokensStreamed(t *testing.T) {
+	f, primary, fallback := newTestFailover(t)
+	primary.set([]string{"Hel", "lo"}, refused)
+	var got string
+	text, err := chatOnce(f, func(tok string) error { got += tok; return nil })
+	if !errors.Is(err, refused) || text != "Hello" || got != "Hello" {
+		t.Fatalf("ChatStream = %q (%q streamed), %v; want the partial reply and the error", text, got, err)
+	}
+	// the client already saw the primary's tokens: retrying elsewhere would mix two replies
+	if fallback.called() != 0 || !f.PrimaryUp() {
+		t.Fatalf("failed over mid-stream (fallback calls %d, primary up %v)", fallback.called(), f.PrimaryUp())
+	}
+}
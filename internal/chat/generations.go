@@ -0,0 +1,68 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrStopped is the cancellation cause of a generation stopped through Cancel.
+var ErrStopped = errors.New("generation stopped")
+
+// ErrGenerationExists is returned when a generation ID is already in flight.
+var ErrGenerationExists = errors.New("generation id already in use")
+
+type generationIDKey struct{}
+
+// WithGenerationID tags ctx so the generation started with it can be cancelled by ID.
+func WithGenerationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, generationIDKey{}, id)
+}
+
+func generationID(ctx context.Context) string {
+	id, _ := ctx.Value(generationIDKey{}).(string)
+	return id
+}
+
+// generations tracks in-flight generations so they can be cancelled from another request.
+type generations struct {
+	mu     sync.Mutex
+	cancel map[string]context.CancelCauseFunc
+}
+
+func newGenerations() *generations {
+	return &generations{cancel: make(map[string]context.CancelCauseFunc)}
+}
+
+// track registers the generation tagged on ctx. The returned func must be called when
+// the generation ends. Untagged contexts are returned unchanged.
+func (g *generations) track(ctx context.Context) (context.Context, func(), error) {
+	id := generationID(ctx)
+	if id == "" {
+		return ctx, func() {}, nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.cancel[id]; ok {
+		return ctx, func() {}, ErrGenerationExists
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	g.cancel[id] = cancel
+	return ctx, func() {
+		g.mu.Lock()
+		delete(g.cancel, id)
+		g.mu.Unlock()
+		cancel(nil)
+	}, nil
+}
+
+// stop cancels the generation with ErrStopped and reports whether it was in flight.
+func (g *generations) stop(id string) bool {
+	g.mu.Lock()
+	cancel, ok := g.cancel[id]
+	g.mu.Unlock()
+	if ok {
+		cancel(ErrStopped)
+	}
+	return ok
+}
@@ -3,19 +3,40 @@ package chat
 import (
 	"context"
 	"github.com/varsilias/zero-downtime/internal/ollama"
+	"github.com/varsilias/zero-downtime/pkg/types"
 	"time"
 )
 
 type OllamaEngine struct {
-	c *ollama.Client
+	c ollama.API
 }
 
-func NewOllamaEngine(c *ollama.Client) *OllamaEngine {
+func NewOllamaEngine(c ollama.API) *OllamaEngine {
 	return &OllamaEngine{
 		c: c,
 	}
 }
 
-func (e *OllamaEngine) Generate(ctx context.Context, model, prompt string) (string, time.Duration, error) {
-	return e.c.Generate(ctx, model, prompt)
+func (e *OllamaEngine) Generate(ctx context.Context, model, prompt string, opts types.GenerateOptions) (string, time.Duration, error) {
+	return e.c.Generate(ctx, model, prompt, opts)
+}
+
+func (e *OllamaEngine) GenerateStream(ctx context.Context, model, prompt string, opts types.GenerateOptions, onToken TokenFunc) (string, time.Duration, error) {
+	return e.c.GenerateStream(ctx, model, prompt, opts, onToken)
+}
+
+func (e *OllamaEngine) Chat(ctx context.Context, model string, history []types.Message, opts types.GenerateOptions) (string, time.Duration, error) {
+	return e.c.Chat(ctx, model, toOllamaMessages(history), opts)
+}
+
+func (e *OllamaEngine) ChatStream(ctx context.Context, model string, history []types.Message, opts types.GenerateOptions, onToken TokenFunc) (string, time.Duration, error) {
+	return e.c.ChatStream(ctx, model, toOllamaMessages(history), opts, onToken)
+}
+
+func toOllamaMessages(history []types.Message) []ollama.ChatMessage {
+	out := make([]ollama.ChatMessage, 0, len(history))
+	for _, m := range history {
+		out = append(out, ollama.ChatMessage{Role: string(m.Role), Content: m.Content})
+	}
+	return out
 }
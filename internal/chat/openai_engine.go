@@ -0,0 +1,48 @@
+package chat
+
+import (
+	"context"
+	"github.com/varsilias/zero-downtime/internal/openai"
+	"github.com/varsilias/zero-downtime/pkg/types"
+	"time"
+)
+
+// OpenAIEngine generates through any OpenAI-compatible server (vLLM, llama.cpp, …).
+// The API has no single-turn endpoint, so Generate sends the prompt as one user message.
+type OpenAIEngine struct {
+	c *openai.Client
+}
+
+func NewOpenAIEngine(c *openai.Client) *OpenAIEngine {
+	return &OpenAIEngine{
+		c: c,
+	}
+}
+
+func (e *OpenAIEngine) Generate(ctx context.Context, model, prompt string, opts types.GenerateOptions) (string, time.Duration, error) {
+	return e.c.Chat(ctx, model, promptMessages(prompt), opts)
+}
+
+func (e *OpenAIEngine) GenerateStream(ctx context.Context, model, prompt string, opts types.GenerateOptions, onToken TokenFunc) (string, time.Duration, error) {
+	return e.c.ChatStream(ctx, model, promptMessages(prompt), opts, onToken)
+}
+
+func (e *OpenAIEngine) Chat(ctx context.Context, model string, history []types.Message, opts types.GenerateOptions) (string, time.Duration, error) {
+	return e.c.Chat(ctx, model, toOpenAIMessages(history), opts)
+}
+
+func (e *OpenAIEngine) ChatStream(ctx context.Context, model string, history []types.Message, opts types.GenerateOptions, onToken TokenFunc) (string, time.Duration, error) {
+	return e.c.ChatStream(ctx, model, toOpenAIMessages(history), opts, onToken)
+}
+
+func promptMessages(prompt string) []openai.ChatMessage {
+	return []openai.ChatMessage{{Role: string(types.RoleUser), Content: prompt}}
+}
+
+func toOpenAIMessages(history []types.Message) []openai.ChatMessage {
+	out := make([]openai.ChatMessage, 0, len(history))
+	for _, m := range history {
+		out = append(out, openai.ChatMessage{Role: string(m.Role), Content: m.Content})
+	}
+	return out
+}
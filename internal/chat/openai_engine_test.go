@@ -0,0 +1,79 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/varsilias/zero-downtime/internal/openai"
+	"github.com/varsilias/zero-downtime/pkg/types"
+)
+
+// newOpenAIServer echoes the roles it was sent, as one reply or as an SSE stream.
+func newOpenAIServer(t *testing.T) *openai.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" || r.Header.Get("Authorization") != "Bearer sk-test" {
+			http.Error(w, `{"error":{"message":"unexpected request"}}`, http.StatusBadRequest)
+			return
+		}
+		var req struct {
+			Messages []openai.ChatMessage `json:"messages"`
+			Stream   bool                 `json:"stream"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		roles := make([]string, 0, len(req.Messages))
+		for _, m := range req.Messages {
+			roles = append(roles, m.Role+":"+m.Content)
+		}
+		reply := strings.Join(roles, " ")
+		if !req.Stream {
+			b, _ := json.Marshal(reply)
+			fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":%s}}]}`, b)
+			return
+		}
+		for _, word := range strings.SplitAfter(reply, " ") {
+			b, _ := json.Marshal(word)
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%s}}]}\n\n", b)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(srv.Close)
+	return openai.NewClient(srv.URL+"/v1", "sk-test", nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestOpenAIEngine(t *testing.T) {
+	e := NewOpenAIEngine(newOpenAIServer(t))
+	ctx := context.Background()
+
+	// no single-turn endpoint: the prompt goes out as one user message
+	text, _, err := e.Generate(ctx, "llama3", "hi", types.GenerateOptions{})
+	if err != nil || text != "user:hi" {
+		t.Fatalf("Generate = %q, %v", text, err)
+	}
+
+	history := []types.Message{
+		{Role: types.RoleSystem, Content: "terse"},
+		{Role: types.RoleUser, Content: "q"},
+		{Role: types.RoleAssistant, Content: "a"},
+		{Role: types.RoleUser, Content: "q2"},
+	}
+	want := "system:terse user:q assistant:a user:q2"
+	if text, _, err := e.Chat(ctx, "llama3", history, types.GenerateOptions{}); err != nil || text != want {
+		t.Fatalf("Chat = %q, %v", text, err)
+	}
+	var n int
+	text, _, err = e.ChatStream(ctx, "llama3", history, types.GenerateOptions{}, func(string) error { n++; return nil })
+	if err != nil || text != want || n != 4 {
+		t.Fatalf("ChatStream = %q (%d tokens), %v", text, n, err)
+	}
+	if text, _, err := e.GenerateStream(ctx, "llama3", "hi", types.GenerateOptions{}, nil); err != nil || text != "user:hi" {
+		t.Fatalf("GenerateStream = %q, %v", text, err)
+	}
+}
@@ -0,0 +1,50 @@
+package chat
+
+// MaxSystemPrompt bounds the size of a session system prompt, in bytes.
+const MaxSystemPrompt = 8000
+
+// Persona is a named system prompt preset that can be applied to a session.
+type Persona struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Prompt string `json:"prompt"`
+}
+
+// Personas is the built-in preset library shown in the composer.
+var Personas = []Persona{
+	{
+		ID:     "assistant",
+		Name:   "Helpful assistant",
+		Prompt: "You are a helpful, concise assistant. Answer directly and say so when you are unsure.",
+	},
+	{
+		ID:     "code-reviewer",
+		Name:   "Code reviewer",
+		Prompt: "You are a senior software engineer reviewing code. Point out bugs, security issues and unclear naming first, then suggest concrete improvements with short code snippets. Be specific and brief.",
+	},
+	{
+		ID:     "sql-helper",
+		Name:   "SQL helper",
+		Prompt: "You are an expert in SQL. Write correct, readable queries, state which dialect you assume (default PostgreSQL), explain joins and indexes briefly, and warn about queries that could be slow or unsafe.",
+	},
+	{
+		ID:     "k8s-operator",
+		Name:   "Kubernetes operator",
+		Prompt: "You are a Kubernetes and cloud operations expert. Prefer kubectl commands and YAML manifests, explain the rollout and rollback implications of each change, and keep answers production-minded.",
+	},
+	{
+		ID:     "explainer",
+		Name:   "Explain like I'm new",
+		Prompt: "You explain technical topics to beginners. Use plain language, short paragraphs and one simple analogy, and avoid jargon unless you define it.",
+	},
+}
+
+// PersonaByID looks up a preset by its ID.
+func PersonaByID(id string) (Persona, bool) {
+	for _, p := range Personas {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return Persona{}, false
+}
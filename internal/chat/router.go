@@ -0,0 +1,72 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/varsilias/zero-downtime/internal/routing"
+	"github.com/varsilias/zero-downtime/pkg/types"
+	"time"
+)
+
+// ErrNoRoute is returned for a model that no route (and no default backend) covers.
+var ErrNoRoute = errors.New("no backend for model")
+
+// Router is a ChatEngine that forwards each call to the backend the routing table
+// picks for the model, e.g. "gemma3:*" to one Ollama and "gpt-*" to a vLLM server.
+type Router struct {
+	table    *routing.Table
+	backends map[string]ChatEngine
+}
+
+func NewRouter(table *routing.Table, backends map[string]ChatEngine) *Router {
+	return &Router{
+		table:    table,
+		backends: backends,
+	}
+}
+
+// Backend returns the name and engine serving model.
+func (r *Router) Backend(model string) (string, ChatEngine, error) {
+	name, ok := r.table.Resolve(model)
+	if !ok {
+		return "", nil, fmt.Errorf("%w %q", ErrNoRoute, model)
+	}
+	e, ok := r.backends[name]
+	if !ok {
+		return "", nil, fmt.Errorf("%w %q: backend %q is not configured", ErrNoRoute, model, name)
+	}
+	return name, e, nil
+}
+
+func (r *Router) Generate(ctx context.Context, model, prompt string, opts types.GenerateOptions) (string, time.Duration, error) {
+	_, e, err := r.Backend(model)
+	if err != nil {
+		return "", 0, err
+	}
+	return e.Generate(ctx, model, prompt, opts)
+}
+
+func (r *Router) GenerateStream(ctx context.Context, model, prompt string, opts types.GenerateOptions, onToken TokenFunc) (string, time.Duration, error) {
+	_, e, err := r.Backend(model)
+	if err != nil {
+		return "", 0, err
+	}
+	return e.GenerateStream(ctx, model, prompt, opts, onToken)
+}
+
+func (r *Router) Chat(ctx context.Context, model string, history []types.Message, opts types.GenerateOptions) (string, time.Duration, error) {
+	_, e, err := r.Backend(model)
+	if err != nil {
+		return "", 0, err
+	}
+	return e.Chat(ctx, model, history, opts)
+}
+
+func (r *Router) ChatStream(ctx context.Context, model string, history []types.Message, opts types.GenerateOptions, onToken TokenFunc) (string, time.Duration, error) {
+	_, e, err := r.Backend(model)
+	if err != nil {
+		return "", 0, err
+	}
+	return e.ChatStream(ctx, model, history, opts, onToken)
+}
@@ -0,0 +1,278 @@
+package chat
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/varsilias/zero-downtime/pkg/types"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrBusy is wrapped by the *BusyError a Scheduler returns when it sheds a request.
+var ErrBusy = errors.New("model busy")
+
+// BusyError reports a request refused because the model's queue is full, or that
+// waited in it longer than the queue timeout. Clients should retry after RetryAfter.
+type BusyError struct {
+	Model      string
+	Reason     string // "queue full" or "queue timeout"
+	RetryAfter time.Duration
+}
+
+func (e *BusyError) Error() string {
+	return fmt.Sprintf("%s: %s (%s), retry in %s", ErrBusy, e.Model, e.Reason, e.RetryAfter)
+}
+
+func (e *BusyError) Unwrap() error { return ErrBusy }
+
+// SchedulerLimits configure a Scheduler; a zero limit leaves a model unscheduled.
+type SchedulerLimits struct {
+	MaxInFlight  int            // concurrent generations per model
+	PerModel     map[string]int // overrides MaxInFlight for a model
+	MaxQueue     int            // waiting requests per model beyond which new ones are refused
+	QueueTimeout time.Duration  // longest a request waits for a slot; 0 waits for as long as its context lives
+}
+
+// QueueFunc receives a request's position in its model's queue (1 = next) whenever it
+// changes, and 0 once the request is running.
+type QueueFunc func(position int)
+
+type queueFuncKey struct{}
+
+// WithQueueFunc asks the Scheduler to report the request's queue position to fn.
+// fn runs on the request's own goroutine.
+func WithQueueFunc(ctx context.Context, fn QueueFunc) context.Context {
+	return context.WithValue(ctx, queueFuncKey{}, fn)
+}
+
+// Scheduler is a ChatEngine in front of another one that caps in-flight generations
+// per model. Requests beyond the cap wait in a bounded FIFO queue, so a CPU-only
+// backend sees at most MaxInFlight generations per model from this replica.
+type Scheduler struct {
+	eng    ChatEngine
+	limits SchedulerLimits
+
+	mu     sync.Mutex
+	models map[string]*modelQueue
+}
+
+type modelQueue struct {
+	inFlight int
+	waiting  *list.List    // of *waiter, oldest first
+	avg      time.Duration // moving average of generation time, for Retry-After
+}
+
+type waiter struct {
+	ready   chan struct{} // closed once the waiter holds a slot
+	granted bool
+	pos     chan int // latest position, buffer of one
+}
+
+func NewScheduler(eng ChatEngine, limits SchedulerLimits) *Scheduler {
+	return &Scheduler{eng: eng, limits: limits, models: map[string]*modelQueue{}}
+}
+
+// Unwrap returns the scheduled engine.
+func (s *Scheduler) Unwrap() Engine { return s.eng }
+
+func (s *Scheduler) limit(model string) int {
+	if n, ok := s.limits.PerModel[model]; ok {
+		return n
+	}
+	return s.limits.MaxInFlight
+}
+
+func (s *Scheduler) queue(model string) *modelQueue {
+	q, ok := s.models[model]
+	if !ok {
+		q = &modelQueue{waiting: list.New()}
+		s.models[model] = q
+	}
+	return q
+}
+
+// acquire waits for a generation slot for model; the returned func releases it.
+func (s *Scheduler) acquire(ctx context.Context, model string) (func(), error) {
+	limit := s.limit(model)
+	if limit <= 0 {
+		return func() {}, nil
+	}
+	notify, _ := ctx.Value(queueFuncKey{}).(QueueFunc)
+
+	s.mu.Lock()
+	q := s.queue(model)
+	if q.inFlight < limit && q.waiting.Len() == 0 {
+		q.inFlight++
+		s.mu.Unlock()
+		return s.releaser(model, time.Now()), nil
+	}
+	if q.waiting.Len() >= s.limits.MaxQueue {
+		err := &BusyError{Model: model, Reason: "queue full", RetryAfter: q.retryAfter(q.waiting.Len()+1, limit)}
+		s.mu.Unlock()
+		return nil, err
+	}
+	w := &waiter{ready: make(chan struct{}), pos: make(chan int, 1)}
+	elem := q.waiting.PushBack(w)
+	w.pos <- q.waiting.Len()
+	s.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if s.limits.QueueTimeout > 0 {
+		t := time.NewTimer(s.limits.QueueTimeout)
+		defer t.Stop()
+		timeout = t.C
+	}
+	for {
+		select {
+		case p := <-w.pos:
+			if notify != nil {
+				notify(p)
+			}
+		case <-w.ready:
+			if notify != nil {
+				notify(0)
+			}
+			return s.releaser(model, time.Now()), nil
+		case <-ctx.Done():
+			s.leave(model, elem, w)
+			return nil, ctx.Err()
+		case <-timeout:
+			s.mu.Lock()
+			retry := q.retryAfter(q.waiting.Len(), limit)
+			s.mu.Unlock()
+			s.leave(model, elem, w)
+			return nil, &BusyError{Model: model, Reason: "queue timeout", RetryAfter: retry}
+		}
+	}
+}
+
+// leave takes a waiter that gave up out of the queue, handing on a slot it was
+// granted in the meantime.
+func (s *Scheduler) leave(model string, elem *list.Element, w *waiter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := s.models[model]
+	if w.granted {
+		q.inFlight--
+		s.grant(q, model)
+		return
+	}
+	q.waiting.Remove(elem)
+	q.renumber()
+}
+
+func (s *Scheduler) releaser(model string, start time.Time) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			q := s.models[model]
+			q.observe(time.Since(start))
+			q.inFlight--
+			s.grant(q, model)
+		})
+	}
+}
+
+// grant hands free slots to the oldest waiters. Callers hold s.mu.
+func (s *Scheduler) grant(q *modelQueue, model string) {
+	limit := s.limit(model)
+	for q.inFlight < limit && q.waiting.Len() > 0 {
+		w := q.waiting.Remove(q.waiting.Front()).(*waiter)
+		w.granted = true
+		q.inFlight++
+		close(w.ready)
+	}
+	q.renumber()
+}
+
+// renumber tells every waiter its current position. Callers hold s.mu.
+func (q *modelQueue) renumber() {
+	i := 1
+	for e := q.waiting.Front(); e != nil; e = e.Next() {
+		w := e.Value.(*waiter)
+		select {
+		case <-w.pos: // drop a stale position nobody read yet
+		default:
+		}
+		w.pos <- i
+		i++
+	}
+}
+
+// observe folds a generation time into the moving average.
+func (q *modelQueue) observe(d time.Duration) {
+	if q.avg == 0 {
+		q.avg = d
+		return
+	}
+	q.avg = (q.avg*4 + d) / 5
+}
+
+// retryAfter estimates how long until position ahead+1 would be served, at least 1s.
+func (q *modelQueue) retryAfter(ahead, limit int) time.Duration {
+	avg := q.avg
+	if avg <= 0 {
+		avg = time.Second
+	}
+	d := time.Duration(math.Ceil(float64(ahead)/float64(limit))) * avg
+	return max(d.Round(time.Second), time.Second)
+}
+
+// QueueStats is the scheduling state of one model.
+type QueueStats struct {
+	InFlight int `json:"in_flight"`
+	Waiting  int `json:"waiting"`
+	Limit    int `json:"limit"`
+}
+
+// Stats reports every model that has been scheduled so far.
+func (s *Scheduler) Stats() map[string]QueueStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]QueueStats, len(s.models))
+	for model, q := range s.models {
+		out[model] = QueueStats{InFlight: q.inFlight, Waiting: q.waiting.Len(), Limit: s.limit(model)}
+	}
+	return out
+}
+
+func (s *Scheduler) Generate(ctx context.Context, model, prompt string, opts types.GenerateOptions) (string, time.Duration, error) {
+	release, err := s.acquire(ctx, model)
+	if err != nil {
+		return "", 0, err
+	}
+	defer release()
+	return s.eng.Generate(ctx, model, prompt, opts)
+}
+
+func (s *Scheduler) GenerateStream(ctx context.Context, model, prompt string, opts types.GenerateOptions, onToken TokenFunc) (string, time.Duration, error) {
+	release, err := s.acquire(ctx, model)
+	if err != nil {
+		return "", 0, err
+	}
+	defer release()
+	return s.eng.GenerateStream(ctx, model, prompt, opts, onToken)
+}
+
+func (s *Scheduler) Chat(ctx context.Context, model string, history []types.Message, opts types.GenerateOptions) (string, time.Duration, error) {
+	release, err := s.acquire(ctx, model)
+	if err != nil {
+		return "", 0, err
+	}
+	defer release()
+	return s.eng.Chat(ctx, model, history, opts)
+}
+
+func (s *Scheduler) ChatStream(ctx context.Context, model string, history []types.Message, opts types.GenerateOptions, onToken TokenFunc) (string, time.Duration, error) {
+	release, err := s.acquire(ctx, model)
+	if err != nil {
+		return "", 0, err
+	}
+	defer release()
+	return s.eng.ChatStream(ctx, model, history, opts, onToken)
+}
@@ -0,0 +1,285 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/varsilias/zero-downtime/pkg/types"
+)
+
+// blockingEngine holds every generation until the test finishes it. The prompt (or
+// the last history message) names the call.
+type blockingEngine struct {
+	started chan string
+
+	mu    sync.Mutex
+	gates map[string]chan error
+}
+
+func newBlockingEngine() *blockingEngine {
+	return &blockingEngine{started: make(chan string, 16), gates: map[string]chan error{}}
+}
+
+func (e *blockingEngine) gate(name string) chan error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	g, ok := e.gates[name]
+	if !ok {
+		g = make(chan error, 1)
+		e.gates[name] = g
+	}
+	return g
+}
+
+// finish lets the named generation return err.
+func (e *blockingEngine) finish(name string, err error) { e.gate(name) <- err }
+
+func (e *blockingEngine) run(ctx context.Context, name string) (string, time.Duration, error) {
+	e.started <- name
+	select {
+	case err := <-e.gate(name):
+		return name, time.Millisecond, err
+	case <-ctx.Done():
+		return "", 0, ctx.Err()
+	}
+}
+
+func (e *blockingEngine) Generate(ctx context.Context, model, prompt string, opts types.GenerateOptions) (string, time.Duration, error) {
+	return e.run(ctx, prompt)
+}
+
+func (e *blockingEngine) GenerateStream(ctx context.Context, model, prompt string, opts types.GenerateOptions, onToken TokenFunc) (string, time.Duration, error) {
+	return e.run(ctx, prompt)
+}
+
+func (e *blockingEngine) Chat(ctx context.Context, model string, history []types.Message, opts types.GenerateOptions) (string, time.Duration, error) {
+	return e.run(ctx, history[len(history)-1].Content)
+}
+
+func (e *blockingEngine) ChatStream(ctx context.Context, model string, history []types.Message, opts types.GenerateOptions, onToken TokenFunc) (string, time.Duration, error) {
+	return e.run(ctx, history[len(history)-1].Content)
+}
+
+// submit runs one named generation through s in the background.
+func submit(ctx context.Context, s *Scheduler, name string) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := s.ChatStream(ctx, "m", []types.Message{{Role: types.RoleUser, Content: name}}, types.GenerateOptions{}, nil)
+		done <- err
+	}()
+	return done
+}
+
+// waitFor polls until cond holds, failing the test after a second.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func queued(s *Scheduler, n int) func() bool {
+	return func() bool { return s.Stats()["m"].Waiting == n }
+}
+
+func expectStart(t *testing.T, e *blockingEngine, want string) {
+	t.Helper()
+	select {
+	case got := <-e.started:
+		if got != want {
+			t.Fatalf("started %q, want %q", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("%q never started", want)
+	}
+}
+
+func expectNoStart(t *testing.T, e *blockingEngine) {
+	t.Helper()
+	select {
+	case got := <-e.started:
+		t.Fatalf("%q started beyond the cap", got)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestSchedulerCap(t *testing.T) {
+	e := newBlockingEngine()
+	s := NewScheduler(e, SchedulerLimits{MaxInFlight: 2, MaxQueue: 8})
+	ctx := context.Background()
+	for _, name := range []string{"a", "b", "c", "d"} {
+		submit(ctx, s, name)
+	}
+	waitFor(t, "two waiters", queued(s, 2))
+	<-e.started
+	<-e.started
+	expectNoStart(t, e)
+	if st := s.Stats()["m"]; st.InFlight != 2 || st.Limit != 2 {
+		t.Fatalf("Stats = %+v, want 2 in flight of 2", st)
+	}
+
+	// other models are not held back by m's queue
+	other := NewScheduler(e, SchedulerLimits{MaxInFlight: 2, PerModel: map[string]int{"m": 0}})
+	done := submit(ctx, other, "unlimited")
+	expectStart(t, e, "unlimited")
+	e.finish("unlimited", nil)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSchedulerFIFO(t *testing.T) {
+	e := newBlockingEngine()
+	s := NewScheduler(e, SchedulerLimits{MaxInFlight: 1, MaxQueue: 8})
+	ctx := context.Background()
+	order := []string{"a", "b", "c", "d"}
+	done := map[string]<-chan error{}
+	for i, name := range order {
+		done[name] = submit(ctx, s, name)
+		if i == 0 {
+			expectStart(t, e, "a")
+		} else {
+			waitFor(t, name+" queued", queued(s, i)) // queue in a known order
+		}
+	}
+	for i, name := range order {
+		if i > 0 {
+			expectStart(t, e, name)
+		}
+		e.finish(name, nil)
+		if err := <-done[name]; err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	if st := s.Stats()["m"]; st.InFlight != 0 || st.Waiting != 0 {
+		t.Fatalf("Stats after draining = %+v", st)
+	}
+}
+
+func TestSchedulerCancelledWaiter(t *testing.T) {
+	e := newBlockingEngine()
+	s := NewScheduler(e, SchedulerLimits{MaxInFlight: 1, MaxQueue: 8})
+	ctx := context.Background()
+	a := submit(ctx, s, "a")
+	expectStart(t, e, "a")
+	bctx, cancelB := context.WithCancel(ctx)
+	b := submit(bctx, s, "b")
+	waitFor(t, "b queued", queued(s, 1))
+	c := submit(ctx, s, "c")
+	waitFor(t, "c queued", queued(s, 2))
+
+	cancelB()
+	if err := <-b; !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled waiter = %v, want context.Canceled", err)
+	}
+	waitFor(t, "b gone", queued(s, 1))
+
+	e.finish("a", nil)
+	<-a
+	expectStart(t, e, "c") // b's place went to c, not to a leaked slot
+	if st := s.Stats()["m"]; st.InFlight != 1 || st.Waiting != 0 {
+		t.Fatalf("Stats = %+v, want c alone in flight", st)
+	}
+	e.finish("c", nil)
+	<-c
+	if st := s.Stats()["m"]; st.InFlight != 0 {
+		t.Fatalf("Stats after c = %+v, want nothing in flight", st)
+	}
+}
+
+func TestSchedulerBusy(t *testing.T) {
+	e := newBlockingEngine()
+	s := NewScheduler(e, SchedulerLimits{MaxInFlight: 1, MaxQueue: 1, QueueTimeout: 30 * time.Millisecond})
+	ctx := context.Background()
+	a := submit(ctx, s, "a")
+	expectStart(t, e, "a")
+
+	b := submit(ctx, s, "b")
+	waitFor(t, "b queued", queued(s, 1))
+	// the queue holds one: the next request is refused straight away
+	_, _, err := s.ChatStream(ctx, "m", []types.Message{{Content: "c"}}, types.GenerateOptions{}, nil)
+	var busy *BusyError
+	if !errors.As(err, &busy) || !errors.Is(err, ErrBusy) || busy.Reason != "queue full" || busy.RetryAfter < time.Second {
+		t.Fatalf("over a full queue: %v", err)
+	}
+
+	err = <-b
+	if !errors.As(err, &busy) || busy.Reason != "queue timeout" || busy.RetryAfter < time.Second {
+		t.Fatalf("after the queue timeout: %v", err)
+	}
+	if st := s.Stats()["m"]; st.InFlight != 1 || st.Waiting != 0 {
+		t.Fatalf("Stats = %+v, want a alone in flight", st)
+	}
+	e.finish("a", nil)
+	<-a
+}
+
+func TestSchedulerReleasesOnError(t *testing.T) {
+	e := newBlockingEngine()
+	s := NewScheduler(e, SchedulerLimits{MaxInFlight: 1, MaxQueue: 8})
+	ctx := context.Background()
+	a := submit(ctx, s, "a")
+	expectStart(t, e, "a")
+	b := submit(ctx, s, "b")
+	waitFor(t, "b queued", queued(s, 1))
+
+	e.finish("a", errEngine)
+	if err := <-a; !errors.Is(err, errEngine) {
+		t.Fatalf("a = %v, want the engine error", err)
+	}
+	expectStart(t, e, "b")
+	e.finish("b", nil)
+	if err := <-b; err != nil {
+		t.Fatal(err)
+	}
+	if st := s.Stats()["m"]; st.InFlight != 0 {
+		t.Fatalf("Stats = %+v, want the failed generation's slot back", st)
+	}
+}
+
+func TestSchedulerQueuePosition(t *testing.T) {
+	e := newBlockingEngine()
+	s := NewScheduler(e, SchedulerLimits{MaxInFlight: 1, MaxQueue: 8})
+	ctx := context.Background()
+	a := submit(ctx, s, "a")
+	expectStart(t, e, "a")
+	b := submit(ctx, s, "b")
+	waitFor(t, "b queued", queued(s, 1))
+
+	var (
+		mu        sync.Mutex
+		positions []int
+	)
+	cctx := WithQueueFunc(ctx, func(p int) {
+		mu.Lock()
+		positions = append(positions, p)
+		mu.Unlock()
+	})
+	seen := func(n int) func() bool {
+		return func() bool { mu.Lock(); defer mu.Unlock(); return len(positions) == n }
+	}
+	c := submit(cctx, s, "c")
+	waitFor(t, "c queued second", seen(1))
+	e.finish("a", nil)
+	<-a
+	expectStart(t, e, "b")
+	waitFor(t, "c moved up", seen(2))
+	e.finish("b", nil)
+	<-b
+	expectStart(t, e, "c")
+	e.finish("c", nil)
+	<-c
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(positions) != 3 || positions[0] != 2 || positions[1] != 1 || positions[2] != 0 {
+		t.Fatalf("positions = %v, want [2 1 0]", positions)
+	}
+}
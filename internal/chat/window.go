@@ -0,0 +1,223 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/varsilias/zero-downtime/pkg/types"
+)
+
+// Strategy selects how history is trimmed when it exceeds a model's context budget.
+type Strategy string
+
+const (
+	// StrategySliding keeps the most recent messages that fit.
+	StrategySliding Strategy = "sliding"
+	// StrategyKeepFirstLast keeps the opening exchange plus the most recent messages.
+	StrategyKeepFirstLast Strategy = "keep-first-last"
+	// StrategySummary replaces the messages that do not fit with an LLM-written summary.
+	StrategySummary Strategy = "summary"
+)
+
+// ParseStrategy maps a config value to a Strategy, defaulting to sliding.
+func ParseStrategy(s string) (Strategy, error) {
+	switch Strategy(strings.ToLower(strings.TrimSpace(s))) {
+	case "", StrategySliding:
+		return StrategySliding, nil
+	case StrategyKeepFirstLast:
+		return StrategyKeepFirstLast, nil
+	case StrategySummary:
+		return StrategySummary, nil
+	default:
+		return "", fmt.Errorf("unknown context strategy %q", s)
+	}
+}
+
+// WindowConfig holds the token budgets used by the ContextBuilder.
+type WindowConfig struct {
+	Strategy      Strategy
+	DefaultBudget int            // tokens available for history when a model has no entry in Budgets
+	Budgets       map[string]int // per-model budgets, keyed by model name
+	KeepFirst     int            // messages pinned at the start by keep-first-last (default 2)
+}
+
+// Window is the slice of history actually sent to the engine.
+type Window struct {
+	Messages []types.Message
+	Dropped  []int // indexes into the original history that were not sent verbatim
+	Summary  bool  // true when Messages starts with a summary of the dropped turns
+	Tokens   int
+	Budget   int
+}
+
+// EstimateTokens is a cheap, model-agnostic estimate: ~4 characters per token plus
+// a small per-message overhead for the role and chat template.
+func EstimateTokens(m types.Message) int {
+	return 4 + (utf8.RuneCountInString(m.Content)+3)/4
+}
+
+// ContextBuilder sits between the Controller and the Engine and fits each session's
+// history into the budget of the model it is sent to.
+type ContextBuilder struct {
+	log *slog.Logger
+	cfg WindowConfig
+	eng Engine
+
+	mu        sync.Mutex
+	summaries map[string]summary // by session ID
+}
+
+type summary struct {
+	upto   int    // number of leading history messages covered
+	lastID string // ID of history[upto-1], so a summary of another branch is not reused
+	text   string
+}
+
+// maxSummaries bounds the summary cache; it is simply reset when full.
+const maxSummaries = 1024
+
+func NewContextBuilder(log *slog.Logger, cfg WindowConfig, eng Engine) *ContextBuilder {
+	if cfg.Strategy == "" {
+		cfg.Strategy = StrategySliding
+	}
+	if cfg.KeepFirst <= 0 {
+		cfg.KeepFirst = 2
+	}
+	return &ContextBuilder{log: log, cfg: cfg, eng: eng, summaries: make(map[string]summary)}
+}
+
+// Budget returns the token budget for model.
+func (b *ContextBuilder) Budget(model string) int {
+	if n, ok := b.cfg.Budgets[model]; ok {
+		return n
+	}
+	return b.cfg.DefaultBudget
+}
+
+// Build trims history for model. reserved tokens (e.g. the system prompt) are taken off
+// the budget first. The latest message is always kept, even if it alone exceeds the
+// budget; a non-positive budget disables trimming.
+func (b *ContextBuilder) Build(ctx context.Context, sessionID, model string, history []types.Message, reserved int) Window {
+	budget := b.Budget(model)
+	if budget > 0 {
+		budget -= reserved
+		if budget < 1 {
+			budget = 1
+		}
+	}
+	total := 0
+	for _, m := range history {
+		total += EstimateTokens(m)
+	}
+	if budget <= 0 || total <= budget || len(history) == 0 {
+		return Window{Messages: history, Tokens: total, Budget: budget}
+	}
+
+	switch b.cfg.Strategy {
+	case StrategyKeepFirstLast:
+		return b.keepFirstLast(history, budget)
+	case StrategySummary:
+		return b.summarize(ctx, sessionID, model, history, budget)
+	default:
+		return sliding(history, budget, 0)
+	}
+}
+
+// sliding keeps history[:pinned] plus as many trailing messages as fit in budget.
+func sliding(history []types.Message, budget, pinned int) Window {
+	used := 0
+	for _, m := range history[:pinned] {
+		used += EstimateTokens(m)
+	}
+	start := len(history)
+	for i := len(history) - 1; i >= pinned; i-- {
+		t := EstimateTokens(history[i])
+		if used+t > budget && i < len(history)-1 {
+			break
+		}
+		used += t
+		start = i
+	}
+
+	w := Window{Tokens: used, Budget: budget}
+	w.Messages = append(w.Messages, history[:pinned]...)
+	w.Messages = append(w.Messages, history[start:]...)
+	for i := pinned; i < start; i++ {
+		w.Dropped = append(w.Dropped, i)
+	}
+	return w
+}
+
+func (b *ContextBuilder) keepFirstLast(history []types.Message, budget int) Window {
+	pinned := b.cfg.KeepFirst
+	if pinned > len(history)-1 {
+		pinned = len(history) - 1
+	}
+	// Only pin the opening messages if they leave room for the latest one.
+	used := EstimateTokens(history[len(history)-1])
+	for _, m := range history[:pinned] {
+		used += EstimateTokens(m)
+	}
+	if used > budget {
+		pinned = 0
+	}
+	return sliding(history, budget, pinned)
+}
+
+// summarize keeps a sliding window in three quarters of the budget and condenses
+// everything before it into one message, reusing and extending the cached summary.
+func (b *ContextBuilder) summarize(ctx context.Context, sessionID, model string, history []types.Message, budget int) Window {
+	w := sliding(history, budget*3/4, 0)
+	if len(w.Dropped) == 0 {
+		return w
+	}
+	upto := len(w.Dropped)
+
+	b.mu.Lock()
+	prev := b.summaries[sessionID]
+	b.mu.Unlock()
+
+	// reuse (and extend) the cached summary only if it covers a prefix of this branch
+	from, text := 0, ""
+	if prev.upto > 0 && prev.upto <= upto && history[prev.upto-1].ID == prev.lastID {
+		from, text = prev.upto, prev.text
+	}
+	if from < upto {
+		var err error
+		text, err = b.summaryOf(ctx, model, text, history[from:upto])
+		if err != nil {
+			b.log.Warn("context summary failed; using sliding window", "model", model, "err", err)
+			return sliding(history, budget, 0)
+		}
+		b.mu.Lock()
+		if len(b.summaries) >= maxSummaries {
+			b.summaries = make(map[string]summary)
+		}
+		b.summaries[sessionID] = summary{upto: upto, lastID: history[upto-1].ID, text: text}
+		b.mu.Unlock()
+	}
+
+	s := types.Message{Role: types.RoleSystem, Content: "Summary of the earlier conversation:\n" + text}
+	w.Messages = append([]types.Message{s}, w.Messages...)
+	w.Tokens += EstimateTokens(s)
+	w.Summary = true
+	return w
+}
+
+func (b *ContextBuilder) summaryOf(ctx context.Context, model, previous string, msgs []types.Message) (string, error) {
+	var sb strings.Builder
+	sb.WriteString("Summarize the following conversation in a few short bullet points. ")
+	sb.WriteString("Keep names, numbers and decisions; reply with the summary only.\n\n")
+	if previous != "" {
+		sb.WriteString("Earlier summary:\n" + previous + "\n\n")
+	}
+	for _, m := range msgs {
+		fmt.Fprintf(&sb, "%s: %s\n", m.Role, m.Content)
+	}
+	text, _, err := b.eng.Generate(ctx, model, sb.String(), types.GenerateOptions{})
+	return strings.TrimSpace(text), err
+}
@@ -0,0 +1,254 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/varsilias/zero-downtime/internal/ollama"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned for an unknown job ID.
+var ErrNotFound = errors.New("job not found")
+
+// ErrFinished is returned when cancelling a job that already ended.
+var ErrFinished = errors.New("job already finished")
+
+// State is where a pull job is in its life cycle.
+type State string
+
+const (
+	StateQueued      State = "queued"
+	StateDownloading State = "downloading"
+	StateVerifying   State = "verifying" // digest check, manifest write
+	StateDone        State = "done"
+	StateFailed      State = "failed"
+	StateCanceled    State = "canceled"
+)
+
+// Finished reports whether s is terminal.
+func (s State) Finished() bool {
+	return s == StateDone || s == StateFailed || s == StateCanceled
+}
+
+// Job is a snapshot of a model pull.
+type Job struct {
+	ID        string    `json:"id"`
+	Model     string    `json:"model"`
+	State     State     `json:"state"`
+	Status    string    `json:"status,omitempty"` // latest Ollama status line
+	Completed int64     `json:"completed"`        // bytes, summed over layers
+	Total     int64     `json:"total"`
+	Error     string    `json:"error,omitempty"`
+	Created   time.Time `json:"created"`
+	Updated   time.Time `json:"updated"`
+}
+
+// PullFunc downloads a model, reporting progress; see ollama.API.Pull.
+type PullFunc func(ctx context.Context, name string, onProgress func(ollama.PullProgress)) error
+
+// MaxFinished is how many finished jobs are kept for GET /admin/jobs.
+const MaxFinished = 100
+
+// Manager runs pulls in the background, at most `workers` at a time; the rest wait
+// queued in submission order.
+type Manager struct {
+	pull PullFunc
+	sem  chan struct{}
+
+	mu   sync.Mutex
+	jobs map[string]*job
+}
+
+type job struct {
+	Job
+	layers map[string][2]int64 // digest -> completed, total
+	cancel context.CancelFunc
+	subs   map[chan Job]struct{}
+}
+
+func NewManager(pull PullFunc, workers int) *Manager {
+	return &Manager{pull: pull, sem: make(chan struct{}, max(workers, 1)), jobs: map[string]*job{}}
+}
+
+// Submit queues a pull of model and returns the new job.
+func (m *Manager) Submit(model string) Job {
+	ctx, cancel := context.WithCancel(context.Background())
+	now := time.Now()
+	j := &job{
+		Job:    Job{ID: newID(), Model: model, State: StateQueued, Created: now, Updated: now},
+		layers: map[string][2]int64{},
+		cancel: cancel,
+		subs:   map[chan Job]struct{}{},
+	}
+	m.mu.Lock()
+	m.prune()
+	m.jobs[j.ID] = j
+	snap := j.Job
+	m.mu.Unlock()
+
+	go m.run(ctx, j)
+	return snap
+}
+
+func (m *Manager) run(ctx context.Context, j *job) {
+	defer j.cancel()
+	select {
+	case m.sem <- struct{}{}:
+		defer func() { <-m.sem }()
+	case <-ctx.Done():
+		m.finish(j, ctx.Err())
+		return
+	}
+	m.update(j, func() { j.State, j.Status = StateDownloading, "starting" })
+	err := m.pull(ctx, j.Model, func(p ollama.PullProgress) {
+		m.update(j, func() { j.progress(p) })
+	})
+	m.finish(j, err)
+}
+
+// progress folds one Ollama status line into the job.
+func (j *job) progress(p ollama.PullProgress) {
+	j.Status = p.Status
+	switch {
+	case strings.HasPrefix(p.Status, "verifying"), strings.HasPrefix(p.Status, "writing"), strings.HasPrefix(p.Status, "removing"):
+		j.State = StateVerifying
+	case p.Status == "success":
+		// a pool reports success per instance; the job is done when the pull returns
+	default:
+		j.State = StateDownloading
+	}
+	if p.Digest != "" && p.Total > 0 {
+		j.layers[p.Digest] = [2]int64{p.Completed, p.Total}
+		j.Completed, j.Total = 0, 0
+		for _, l := range j.layers {
+			j.Completed += l[0]
+			j.Total += l[1]
+		}
+	}
+}
+
+func (m *Manager) finish(j *job, err error) {
+	m.update(j, func() {
+		switch {
+		case err == nil:
+			j.State, j.Completed = StateDone, j.Total
+		case errors.Is(err, context.Canceled):
+			j.State, j.Error = StateCanceled, "canceled"
+		default:
+			j.State, j.Error = StateFailed, err.Error()
+		}
+		j.Status = string(j.State)
+	})
+	m.mu.Lock()
+	for ch := range j.subs {
+		close(ch)
+	}
+	j.subs = nil
+	m.mu.Unlock()
+}
+
+// update applies fn to j and hands the new snapshot to every subscriber, replacing
+// a snapshot a slow subscriber has not read yet.
+func (m *Manager) update(j *job, fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fn()
+	j.Updated = time.Now()
+	for ch := range j.subs {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- j.Job
+	}
+}
+
+// Get returns the job with id.
+func (m *Manager) Get(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	return j.Job, nil
+}
+
+// List returns every job, newest first.
+func (m *Manager) List() []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]Job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		out = append(out, j.Job)
+	}
+	sort.Slice(out, func(i, k int) bool { return out[i].Created.After(out[k].Created) })
+	return out
+}
+
+// Cancel stops a queued or running job.
+func (m *Manager) Cancel(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if j.State.Finished() {
+		return ErrFinished
+	}
+	j.cancel()
+	return nil
+}
+
+// Subscribe returns the current snapshot and a channel of later ones, closed when the
+// job finishes (nil if it already has). Call stop when no longer interested.
+func (m *Manager) Subscribe(id string) (Job, <-chan Job, func(), error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return Job{}, nil, nil, ErrNotFound
+	}
+	if j.subs == nil {
+		return j.Job, nil, func() {}, nil
+	}
+	ch := make(chan Job, 1)
+	j.subs[ch] = struct{}{}
+	stop := func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if _, ok := j.subs[ch]; ok {
+			delete(j.subs, ch)
+		}
+	}
+	return j.Job, ch, stop, nil
+}
+
+// prune drops the oldest finished jobs beyond MaxFinished. Callers hold m.mu.
+func (m *Manager) prune() {
+	var done []*job
+	for _, j := range m.jobs {
+		if j.State.Finished() {
+			done = append(done, j)
+		}
+	}
+	if len(done) <= MaxFinished {
+		return
+	}
+	sort.Slice(done, func(i, k int) bool { return done[i].Updated.Before(done[k].Updated) })
+	for _, j := range done[:len(done)-MaxFinished] {
+		delete(m.jobs, j.ID)
+	}
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return "job-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,45 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/varsilias/zero-downtime/internal/ollama"
+)
+
+// TestSubscribeEndsWithFinalSnapshot checks that a subscriber always reads the
+// terminal state before its channel closes, however slowly it reads.
+func TestSubscribeEndsWithFinalSnapshot(t *testing.T) {
+	release := make(chan struct{})
+	m := NewManager(func(ctx context.Context, name string, onProgress func(ollama.PullProgress)) error {
+		<-release
+		for i := int64(1); i <= 5; i++ {
+			onProgress(ollama.PullProgress{Status: "pulling", Digest: "sha256:a", Completed: i, Total: 5})
+		}
+		return errors.New("disk full")
+	}, 1)
+	job := m.Submit("gemma3:270m")
+	_, updates, stop, err := m.Subscribe(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	close(release)
+	time.Sleep(20 * time.Millisecond) // let every update land before reading
+
+	var last Job
+	for j := range updates {
+		last = j
+	}
+	if last.State != StateFailed || last.Error != "disk full" || last.Completed != 5 {
+		t.Fatalf("last snapshot = %+v, want failed with 5/5 bytes", last)
+	}
+	if _, ch, _, _ := m.Subscribe(job.ID); ch != nil {
+		t.Fatal("subscribing to a finished job should return no channel")
+	}
+	if _, err := m.Get("job-missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get(missing) = %v, want ErrNotFound", err)
+	}
+}
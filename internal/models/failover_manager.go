@@ -0,0 +1,29 @@
+package models
+
+import "context"
+
+// FailoverManager lists the primary's models while it is up and the fallback's
+// otherwise, following the engine's failover (see chat.Failover.PrimaryUp).
+type FailoverManager struct {
+	primary, fallback Manager
+	primaryUp         func() bool
+}
+
+func NewFailoverManager(primary, fallback Manager, primaryUp func() bool) *FailoverManager {
+	return &FailoverManager{primary: primary, fallback: fallback, primaryUp: primaryUp}
+}
+
+func (m *FailoverManager) active() Manager {
+	if m.primaryUp() {
+		return m.primary
+	}
+	return m.fallback
+}
+
+func (m *FailoverManager) List(ctx context.Context) ([]ModelInfo, error) {
+	return m.active().List(ctx)
+}
+
+func (m *FailoverManager) Healthy(ctx context.Context, model string) error {
+	return m.active().Healthy(ctx, model)
+}
@@ -3,19 +3,47 @@ package models
 import (
 	"context"
 	"errors"
+	"time"
 )
 
+// ModelInfo is a model of the catalogue with whatever its backend reports about it;
+// only Name is always set.
+type ModelInfo struct {
+	Name          string     `json:"name"`
+	Backend       string     `json:"backend,omitempty"` // set when several backends are routed
+	Size          int64      `json:"size,omitempty"`    // bytes on disk
+	Digest        string     `json:"digest,omitempty"`
+	Family        string     `json:"family,omitempty"`
+	ParameterSize string     `json:"parameter_size,omitempty"` // e.g. "268.10M"
+	Quantization  string     `json:"quantization,omitempty"`   // e.g. "Q8_0"
+	Format        string     `json:"format,omitempty"`
+	ModifiedAt    *time.Time `json:"modified_at,omitempty"`
+}
+
 type Manager interface {
-	List(ctx context.Context) ([]string, error)
+	List(ctx context.Context) ([]ModelInfo, error)
 	Healthy(ctx context.Context, model string) error
 }
 
+// Names returns the names of infos, in order.
+func Names(infos []ModelInfo) []string {
+	out := make([]string, 0, len(infos))
+	for _, m := range infos {
+		out = append(out, m.Name)
+	}
+	return out
+}
+
 type StaticManager struct{ items []string }
 
 func NewStaticManager(items []string) *StaticManager { return &StaticManager{items: items} }
 
-func (m *StaticManager) List(ctx context.Context) ([]string, error) {
-	return append([]string(nil), m.items...), nil
+func (m *StaticManager) List(ctx context.Context) ([]ModelInfo, error) {
+	out := make([]ModelInfo, 0, len(m.items))
+	for _, name := range m.items {
+		out = append(out, ModelInfo{Name: name})
+	}
+	return out, nil
 }
 
 func (m *StaticManager) Healthy(ctx context.Context, model string) error {
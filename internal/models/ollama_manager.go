@@ -5,18 +5,32 @@ import (
 	"github.com/varsilias/zero-downtime/internal/ollama"
 )
 
-type OllamaManager struct{ c *ollama.Client }
+type OllamaManager struct{ c ollama.API }
 
-func NewOllamaManager(c *ollama.Client) *OllamaManager { return &OllamaManager{c: c} }
+func NewOllamaManager(c ollama.API) *OllamaManager { return &OllamaManager{c: c} }
 
-func (m *OllamaManager) List(ctx context.Context) ([]string, error) {
+// List reports size, digest, family, parameter size and quantization from /api/tags.
+func (m *OllamaManager) List(ctx context.Context) ([]ModelInfo, error) {
 	items, err := m.c.Tags(ctx)
 	if err != nil {
 		return nil, err
 	}
-	out := make([]string, 0, len(items))
+	out := make([]ModelInfo, 0, len(items))
 	for _, it := range items {
-		out = append(out, it.Name)
+		info := ModelInfo{
+			Name:          it.Name,
+			Size:          it.Size,
+			Digest:        it.Digest,
+			Family:        it.Details.Family,
+			ParameterSize: it.Details.ParameterSize,
+			Quantization:  it.Details.QuantizationLevel,
+			Format:        it.Details.Format,
+		}
+		if !it.ModifiedAt.IsZero() {
+			t := it.ModifiedAt
+			info.ModifiedAt = &t
+		}
+		out = append(out, info)
 	}
 	return out, nil
 }
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"context"
+	"github.com/varsilias/zero-downtime/internal/openai"
+	"time"
+)
+
+// OpenAIManager lists the models of an OpenAI-compatible server.
+type OpenAIManager struct{ c *openai.Client }
+
+func NewOpenAIManager(c *openai.Client) *OpenAIManager { return &OpenAIManager{c: c} }
+
+// List reports only names and creation times; /models carries nothing else.
+func (m *OpenAIManager) List(ctx context.Context) ([]ModelInfo, error) {
+	items, err := m.c.Models(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ModelInfo, 0, len(items))
+	for _, it := range items {
+		info := ModelInfo{Name: it.ID}
+		if it.Created > 0 {
+			t := time.Unix(it.Created, 0).UTC()
+			info.ModifiedAt = &t
+		}
+		out = append(out, info)
+	}
+	return out, nil
+}
+
+func (m *OpenAIManager) Healthy(ctx context.Context, model string) error {
+	items, err := m.c.Models(ctx)
+	if err != nil {
+		return err
+	}
+	for _, it := range items {
+		if it.ID == model {
+			return nil
+		}
+	}
+	return ErrUnknownModel
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/varsilias/zero-downtime/internal/openai"
+)
+
+func TestOpenAIManager(t *testing.T) {
+	var down atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			http.NotFound(w, r)
+			return
+		}
+		if down.Load() {
+			http.Error(w, "maintenance", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"object":"list","data":[{"id":"llama3","created":1700000000},{"id":"qwen2","created":0}]}`)
+	}))
+	defer srv.Close()
+	m := NewOpenAIManager(openai.NewClient(srv.URL+"/v1", "", nil, slog.New(slog.NewTextHandler(io.Discard, nil))))
+	ctx := context.Background()
+
+	infos, err := m.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 2 || infos[0].Name != "llama3" || infos[1].Name != "qwen2" {
+		t.Fatalf("List = %+v", infos)
+	}
+	if at := infos[0].ModifiedAt; at == nil || !at.Equal(time.Unix(1700000000, 0)) {
+		t.Fatalf("llama3 ModifiedAt = %v", at)
+	}
+	if infos[1].ModifiedAt != nil {
+		t.Fatalf("qwen2 without created: ModifiedAt = %v, want nil", infos[1].ModifiedAt)
+	}
+
+	if err := m.Healthy(ctx, "llama3"); err != nil {
+		t.Fatalf("Healthy(llama3) = %v", err)
+	}
+	if err := m.Healthy(ctx, "mistral"); !errors.Is(err, ErrUnknownModel) {
+		t.Fatalf("Healthy(mistral) = %v, want ErrUnknownModel", err)
+	}
+	down.Store(true)
+	if _, err := m.List(ctx); err == nil || err.Error() != "openai models: 503 Service Unavailable: maintenance" {
+		t.Fatalf("List while down = %v", err)
+	}
+}
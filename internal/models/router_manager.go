@@ -0,0 +1,71 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/varsilias/zero-downtime/internal/routing"
+	"log/slog"
+	"sort"
+)
+
+// RouterManager merges the catalogues of several backends through a routing table.
+type RouterManager struct {
+	table    *routing.Table
+	backends map[string]Manager
+	log      *slog.Logger
+}
+
+func NewRouterManager(table *routing.Table, backends map[string]Manager, log *slog.Logger) *RouterManager {
+	return &RouterManager{table: table, backends: backends, log: log}
+}
+
+// List lists every backend and keeps each model once, under the backend the table
+// routes it to (set as Backend); models a backend serves but that are routed elsewhere
+// are dropped. A backend that cannot be listed is skipped unless all of them fail.
+func (m *RouterManager) List(ctx context.Context) ([]ModelInfo, error) {
+	names := make([]string, 0, len(m.backends))
+	for name := range m.backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var (
+		out  []ModelInfo
+		seen = map[string]bool{}
+		errs []error
+	)
+	for _, name := range names {
+		items, err := m.backends[name].List(ctx)
+		if err != nil {
+			m.log.Warn("list models", "backend", name, "err", err)
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		for _, info := range items {
+			if b, ok := m.table.Resolve(info.Name); !ok || b != name || seen[info.Name] {
+				continue
+			}
+			seen[info.Name] = true
+			info.Backend = name
+			out = append(out, info)
+		}
+	}
+	if len(errs) == len(names) && len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+func (m *RouterManager) Healthy(ctx context.Context, model string) error {
+	name, ok := m.table.Resolve(model)
+	if !ok {
+		return ErrUnknownModel
+	}
+	b, ok := m.backends[name]
+	if !ok {
+		return ErrUnknownModel
+	}
+	return b.Healthy(ctx, model)
+}
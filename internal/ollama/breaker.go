@@ -0,0 +1,179 @@
+package ollama
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without contacting Ollama while an endpoint's breaker is open.
+var ErrCircuitOpen = errors.New("circuit open")
+
+// Resilience configures retries and circuit breaking; zero disables each part.
+type Resilience struct {
+	Retries     int           // extra attempts for idempotent calls (version, tags, ps, show)
+	RetryBase   time.Duration // first backoff; doubles per attempt, with full jitter
+	RetryMax    time.Duration // backoff cap
+	Failures    int           // consecutive failures that open an endpoint's breaker
+	OpenTimeout time.Duration // how long a breaker stays open before a half-open trial
+}
+
+// Published on /debug/vars, keyed by endpoint URL.
+var (
+	// CircuitStates holds each breaker's state: closed, open or half-open.
+	CircuitStates = expvar.NewMap("ollama_circuit_state")
+	// Retries counts retried attempts.
+	Retries = expvar.NewMap("ollama_retries")
+	// ShortCircuits counts calls refused by an open breaker.
+	ShortCircuits = expvar.NewMap("ollama_short_circuits")
+)
+
+type State int
+
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "closed"
+}
+
+func (s State) MarshalText() ([]byte, error) { return []byte(s.String()), nil }
+
+// Breaker is the circuit breaker of one endpoint. Closed, it counts consecutive
+// failures (transport errors and 5xx) and opens at the threshold. Open, it fails fast
+// until the open timeout has passed, then lets a single trial call through (half-open):
+// success closes it, failure opens it again.
+type Breaker struct {
+	name      string
+	threshold int
+	timeout   time.Duration
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	trial    bool // a half-open trial call is in flight
+	gauge    *expvar.String
+}
+
+func newBreaker(name string, threshold int, timeout time.Duration) *Breaker {
+	b := &Breaker{name: name, threshold: threshold, timeout: timeout, gauge: new(expvar.String)}
+	b.gauge.Set(StateClosed.String())
+	CircuitStates.Set(name, b.gauge)
+	return b
+}
+
+// Allow reports whether a call may proceed; each allowed call must end in Record or Release.
+func (b *Breaker) Allow() error {
+	if b.threshold <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case StateOpen:
+		if wait := b.timeout - time.Since(b.openedAt); wait > 0 {
+			ShortCircuits.Add(b.name, 1)
+			return fmt.Errorf("%w (retry in %s)", ErrCircuitOpen, wait.Round(time.Second))
+		}
+		b.set(StateHalfOpen)
+		b.trial = true
+	case StateHalfOpen:
+		if b.trial {
+			ShortCircuits.Add(b.name, 1)
+			return fmt.Errorf("%w (half-open, trial in flight)", ErrCircuitOpen)
+		}
+		b.trial = true
+	}
+	return nil
+}
+
+// Record reports the outcome of an allowed call.
+func (b *Breaker) Record(ok bool) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case StateClosed:
+		if ok {
+			b.failures = 0
+			return
+		}
+		if b.failures++; b.failures >= b.threshold {
+			b.open()
+		}
+	case StateHalfOpen:
+		b.trial = false
+		if ok {
+			b.failures = 0
+			b.set(StateClosed)
+		} else {
+			b.open()
+		}
+	}
+	// StateOpen: a call allowed before the breaker opened; its outcome is stale
+}
+
+// Release ends an allowed call without a verdict (e.g. the caller gave up).
+func (b *Breaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == StateHalfOpen {
+		b.trial = false
+	}
+}
+
+func (b *Breaker) open() {
+	b.openedAt = time.Now()
+	b.set(StateOpen)
+}
+
+func (b *Breaker) set(s State) {
+	b.state = s
+	b.gauge.Set(s.String())
+}
+
+// CircuitStatus is a breaker's state as reported by the admin API.
+type CircuitStatus struct {
+	Endpoint string     `json:"endpoint"`
+	State    State      `json:"state"`
+	Failures int        `json:"failures"`
+	OpenedAt *time.Time `json:"opened_at,omitempty"`
+}
+
+func (b *Breaker) Status() CircuitStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := CircuitStatus{Endpoint: b.name, State: b.state, Failures: b.failures}
+	if b.state != StateClosed {
+		t := b.openedAt
+		s.OpenedAt = &t
+	}
+	return s
+}
+
+// backoff returns the full-jitter delay before retry attempt n (0-based).
+func (r Resilience) backoff(n int) time.Duration {
+	d := r.RetryBase << n
+	if d <= 0 || (r.RetryMax > 0 && d > r.RetryMax) {
+		d = r.RetryMax
+	}
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d)
+}
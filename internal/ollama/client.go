@@ -6,44 +6,154 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/varsilias/zero-downtime/pkg/types"
 	"io"
 	"log/slog"
 	"net/http"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
+// API is what the app needs from Ollama; *Client talks to one instance, *Pool to several.
+type API interface {
+	Ping(ctx context.Context) error
+	Generate(ctx context.Context, model, prompt string, opts types.GenerateOptions) (string, time.Duration, error)
+	GenerateStream(ctx context.Context, model, prompt string, opts types.GenerateOptions, onToken func(string) error) (string, time.Duration, error)
+	Chat(ctx context.Context, model string, messages []ChatMessage, opts types.GenerateOptions) (string, time.Duration, error)
+	ChatStream(ctx context.Context, model string, messages []ChatMessage, opts types.GenerateOptions, onToken func(string) error) (string, time.Duration, error)
+	Tags(ctx context.Context) ([]TagModel, error)
+	PS(ctx context.Context) ([]RunningModel, error)
+	Load(ctx context.Context, model string) (time.Duration, error)
+	Unload(ctx context.Context, model string) error
+	Show(ctx context.Context, name string) (ModelInfo, error)
+	Pull(ctx context.Context, name string, onProgress func(PullProgress)) error
+	Delete(ctx context.Context, name string) error
+	Copy(ctx context.Context, source, destination string) error
+	Create(ctx context.Context, req CreateRequest) error
+	Circuits() []CircuitStatus
+}
+
+// ErrModelNotFound is returned when Ollama answers 404 for a model.
+var ErrModelNotFound = errors.New("model not found")
+
 type Client struct {
-	baseURL string
-	log     *slog.Logger
-	client  *http.Client
+	baseURL   string
+	log       *slog.Logger
+	client    *http.Client
+	res       Resilience
+	keepAlive KeepAlive
+	breakers  map[string]*Breaker // per endpoint, e.g. "chat"
 }
 
+// endpoints are the Ollama API paths the client calls, each behind its own breaker.
+var endpoints = []string{"version", "tags", "ps", "show", "generate", "chat", "pull", "delete", "copy", "create"}
+
 type TagModel struct {
-	Name       string    `json:"name"`
-	Model      string    `json:"model"`
-	Digest     string    `json:"digest"`
-	Size       int64     `json:"size"`
-	ModifiedAt time.Time `json:"modified_at"`
-	Details    any       `json:"details"`
+	Name       string       `json:"name"`
+	Model      string       `json:"model"`
+	Digest     string       `json:"digest"`
+	Size       int64        `json:"size"`
+	ModifiedAt time.Time    `json:"modified_at"`
+	Details    ModelDetails `json:"details"`
+}
+
+// NewClient talks to the Ollama instance at baseURL; keepAlive is sent with every
+// generate and chat call (nil leaves Ollama's default).
+func NewClient(baseURL string, log *slog.Logger, res Resilience, keepAlive KeepAlive) *Client {
+	c := &Client{
+		baseURL:   baseURL,
+		log:       log,
+		client:    &http.Client{Timeout: 240 * time.Second}, // non-streamed calls; streams, pulls and creates use httpNoTimeout
+		res:       res,
+		keepAlive: keepAlive,
+		breakers:  map[string]*Breaker{},
+	}
+	for _, ep := range endpoints {
+		c.breakers[ep] = newBreaker(fmt.Sprintf("%s/api/%s", baseURL, ep), res.Failures, res.OpenTimeout)
+	}
+	return c
+}
+
+// Circuits reports the breaker of every endpoint.
+func (c *Client) Circuits() []CircuitStatus {
+	out := make([]CircuitStatus, 0, len(endpoints))
+	for _, ep := range endpoints {
+		out = append(out, c.breakers[ep].Status())
+	}
+	return out
 }
 
-func NewClient(baseURL string, log *slog.Logger) *Client {
-	return &Client{
-		baseURL: baseURL,
-		log:     log,
-		client:  &http.Client{Timeout: 240 * time.Second}, // to handle log ops like model pull
+// do sends a request to /api/{endpoint} through the endpoint's breaker. Idempotent
+// calls are retried with jittered backoff on transport errors and 5xx; the last
+// response is returned as is, so callers still see its status. body is re-read
+// on every attempt.
+func (c *Client) do(ctx context.Context, method, endpoint string, body []byte, idempotent bool) (*http.Response, error) {
+	hc := c.client
+	if endpoint == "pull" || endpoint == "create" {
+		hc = httpNoTimeout // downloads and quantization outlive the 240s client timeout
+	}
+	return c.send(ctx, hc, method, endpoint, body, idempotent)
+}
+
+// send is do over a given HTTP client.
+func (c *Client) send(ctx context.Context, hc *http.Client, method, endpoint string, body []byte, idempotent bool) (*http.Response, error) {
+	br := c.breakers[endpoint]
+	attempts := 1
+	if idempotent {
+		attempts += max(c.res.Retries, 0)
+	}
+	for n := 0; ; n++ {
+		if err := br.Allow(); err != nil {
+			return nil, fmt.Errorf("ollama %s: %w", endpoint, err)
+		}
+		var r io.Reader
+		if body != nil {
+			r = bytes.NewReader(body)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("%s/api/%s", c.baseURL, endpoint), r)
+		if err != nil {
+			br.Release()
+			return nil, err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		res, err := hc.Do(req)
+		if ctx.Err() != nil {
+			// the caller gave up: says nothing about Ollama's health
+			br.Release()
+			if err == nil {
+				res.Body.Close()
+			}
+			return nil, ctx.Err()
+		}
+		failed := err != nil || res.StatusCode >= 500
+		br.Record(!failed)
+		if !failed || n+1 >= attempts {
+			return res, err
+		}
+		if err == nil {
+			res.Body.Close()
+		}
+		Retries.Add(br.name, 1)
+		c.log.Debug("ollama retry", "endpoint", endpoint, "attempt", n+1, "err", err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(c.res.backoff(n)):
+		}
 	}
 }
 
 func (c *Client) Ping(ctx context.Context) error {
-	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/api/version", c.baseURL), nil)
-	res, err := c.client.Do(req)
+	res, err := c.do(ctx, http.MethodGet, "version", nil, true)
 	if err != nil {
 		return err
 	}
 	data, _ := io.ReadAll(res.Body)
 	res.Body.Close()
-	c.log.Info("ping response", "response", string(data))
+	c.log.Debug("ping response", "response", string(data))
 	if res.StatusCode > 400 {
 		return fmt.Errorf("ollama ping status: %d", res.StatusCode)
 	}
@@ -52,13 +162,11 @@ func (c *Client) Ping(ctx context.Context) error {
 }
 
 // Generate sends a single-turn generation (non-stream) via /api/generate.
-func (c *Client) Generate(ctx context.Context, model, prompt string) (string, time.Duration, error) {
-	payload := map[string]any{"model": model, "prompt": prompt, "stream": false}
+func (c *Client) Generate(ctx context.Context, model, prompt string, opts types.GenerateOptions) (string, time.Duration, error) {
+	payload := c.withKeepAlive(withOptions(map[string]any{"model": model, "prompt": prompt, "stream": false}, opts))
 	b, _ := json.Marshal(payload)
-	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/api/generate", c.baseURL), bytes.NewReader(b))
-	req.Header.Set("Content-Type", "application/json")
 	start := time.Now()
-	res, err := c.client.Do(req)
+	res, err := c.do(ctx, http.MethodPost, "generate", b, false)
 	if err != nil {
 		return "", 0, err
 	}
@@ -76,10 +184,127 @@ func (c *Client) Generate(ctx context.Context, model, prompt string) (string, ti
 	return out.Response, time.Since(start), nil
 }
 
+// GenerateStream sends a single-turn generation via /api/generate with "stream": true.
+// Ollama answers with NDJSON; onToken is called for every non-empty chunk and the
+// full text is returned once the model reports done.
+func (c *Client) GenerateStream(ctx context.Context, model, prompt string, opts types.GenerateOptions, onToken func(string) error) (string, time.Duration, error) {
+	payload := c.withKeepAlive(withOptions(map[string]any{"model": model, "prompt": prompt, "stream": true}, opts))
+	b, _ := json.Marshal(payload)
+	start := time.Now()
+	res, err := c.stream(ctx, "generate", b)
+	if err != nil {
+		return "", 0, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 400 {
+		body, _ := io.ReadAll(res.Body)
+		return "", 0, fmt.Errorf("ollama generate: %s", string(body))
+	}
+
+	text, err := readStream(res.Body, "generate", onToken)
+	return text, time.Since(start), err
+}
+
+// ChatMessage is one turn of a conversation sent to /api/chat.
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Chat sends the whole conversation (non-stream) via /api/chat and returns the reply.
+func (c *Client) Chat(ctx context.Context, model string, messages []ChatMessage, opts types.GenerateOptions) (string, time.Duration, error) {
+	payload := c.withKeepAlive(withOptions(map[string]any{"model": model, "messages": messages, "stream": false}, opts))
+	b, _ := json.Marshal(payload)
+	start := time.Now()
+	res, err := c.do(ctx, http.MethodPost, "chat", b, false)
+	if err != nil {
+		return "", 0, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 400 {
+		body, _ := io.ReadAll(res.Body)
+		return "", 0, fmt.Errorf("ollama chat: %s", string(body))
+	}
+	var out struct {
+		Message ChatMessage `json:"message"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return "", 0, err
+	}
+	return out.Message.Content, time.Since(start), nil
+}
+
+// ChatStream is Chat with "stream": true; onToken is called for every NDJSON chunk.
+func (c *Client) ChatStream(ctx context.Context, model string, messages []ChatMessage, opts types.GenerateOptions, onToken func(string) error) (string, time.Duration, error) {
+	payload := c.withKeepAlive(withOptions(map[string]any{"model": model, "messages": messages, "stream": true}, opts))
+	b, _ := json.Marshal(payload)
+	start := time.Now()
+	res, err := c.stream(ctx, "chat", b)
+	if err != nil {
+		return "", 0, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 400 {
+		body, _ := io.ReadAll(res.Body)
+		return "", 0, fmt.Errorf("ollama chat: %s", string(body))
+	}
+	text, err := readStream(res.Body, "chat", onToken)
+	return text, time.Since(start), err
+}
+
+// withOptions adds the "options" object to a generate/chat payload when any option is set.
+func withOptions(payload map[string]any, opts types.GenerateOptions) map[string]any {
+	if !opts.IsZero() {
+		payload["options"] = opts
+	}
+	return payload
+}
+
+// streamChunk covers the NDJSON lines of both /api/generate and /api/chat.
+type streamChunk struct {
+	Response string      `json:"response"`
+	Message  ChatMessage `json:"message"`
+	Done     bool        `json:"done"`
+	Error    string      `json:"error"`
+}
+
+// readStream decodes NDJSON chunks until done, forwarding text to onToken.
+// The text collected so far is returned alongside any error.
+func readStream(r io.Reader, op string, onToken func(string) error) (string, error) {
+	var sb strings.Builder
+	dec := json.NewDecoder(r)
+	for {
+		var chunk streamChunk
+		if err := dec.Decode(&chunk); err != nil {
+			if errors.Is(err, io.EOF) {
+				return sb.String(), nil
+			}
+			return sb.String(), err
+		}
+		if chunk.Error != "" {
+			return sb.String(), fmt.Errorf("ollama %s: %s", op, chunk.Error)
+		}
+		text := chunk.Response
+		if text == "" {
+			text = chunk.Message.Content
+		}
+		if text != "" {
+			sb.WriteString(text)
+			if onToken != nil {
+				if err := onToken(text); err != nil {
+					return sb.String(), err
+				}
+			}
+		}
+		if chunk.Done {
+			return sb.String(), nil
+		}
+	}
+}
+
 // Tags lists local models via GET /api/tags.
 func (c *Client) Tags(ctx context.Context) ([]TagModel, error) {
-	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/api/tags", c.baseURL), nil)
-	res, err := c.client.Do(req)
+	res, err := c.do(ctx, http.MethodGet, "tags", nil, true)
 	if err != nil {
 		return nil, err
 	}
@@ -96,36 +321,276 @@ func (c *Client) Tags(ctx context.Context) ([]TagModel, error) {
 	return out.Models, nil
 }
 
+// RunningModel is a model loaded in memory, as listed by GET /api/ps.
+type RunningModel struct {
+	Name      string       `json:"name"`
+	Model     string       `json:"model"`
+	Size      int64        `json:"size"`      // memory in use, bytes
+	SizeVRAM  int64        `json:"size_vram"` // of which on the GPU
+	Details   ModelDetails `json:"details"`
+	ExpiresAt time.Time    `json:"expires_at"`         // when Ollama unloads it if idle
+	Endpoint  string       `json:"endpoint,omitempty"` // instance, set by a Pool
+}
+
+// PS lists the models currently loaded via GET /api/ps.
+func (c *Client) PS(ctx context.Context) ([]RunningModel, error) {
+	res, err := c.do(ctx, http.MethodGet, "ps", nil, true)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 400 {
+		return nil, fmt.Errorf("ollama ps: %s", res.Status)
+	}
+	var out struct {
+		Models []RunningModel `json:"models"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return out.Models, nil
+}
+
+// ModelInfo describes a local model, as returned by POST /api/show. Parameters is
+// Ollama's text form, one "name value" per line.
+type ModelInfo struct {
+	Name         string         `json:"name"` // set by Show; Ollama does not echo it
+	Modelfile    string         `json:"modelfile,omitempty"`
+	Parameters   string         `json:"parameters,omitempty"`
+	Template     string         `json:"template,omitempty"`
+	System       string         `json:"system,omitempty"`
+	License      string         `json:"license,omitempty"`
+	Details      ModelDetails   `json:"details"`
+	ModelInfo    map[string]any `json:"model_info,omitempty"`
+	Capabilities []string       `json:"capabilities,omitempty"`
+	ModifiedAt   time.Time      `json:"modified_at"`
+}
+
+// ModelDetails is the "details" object of /api/show and /api/tags.
+type ModelDetails struct {
+	ParentModel       string   `json:"parent_model,omitempty"`
+	Format            string   `json:"format,omitempty"`
+	Family            string   `json:"family,omitempty"`
+	Families          []string `json:"families,omitempty"`
+	ParameterSize     string   `json:"parameter_size,omitempty"`
+	QuantizationLevel string   `json:"quantization_level,omitempty"`
+}
+
+// Show describes a local model via POST /api/show (read-only, so retried like tags).
+func (c *Client) Show(ctx context.Context, name string) (ModelInfo, error) {
+	b, _ := json.Marshal(map[string]any{"model": name})
+	res, err := c.do(ctx, http.MethodPost, "show", b, true)
+	if err != nil {
+		return ModelInfo{}, err
+	}
+	defer res.Body.Close()
+	if err := statusError(res, "show", name); err != nil {
+		return ModelInfo{}, err
+	}
+	out := ModelInfo{Name: name}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return ModelInfo{}, err
+	}
+	return out, nil
+}
+
+// Delete removes a local model via DELETE /api/delete.
+func (c *Client) Delete(ctx context.Context, name string) error {
+	b, _ := json.Marshal(map[string]any{"model": name})
+	res, err := c.do(ctx, http.MethodDelete, "delete", b, false)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if err := statusError(res, "delete", name); err != nil {
+		return err
+	}
+	c.log.Info("ollama model deleted", "model", name)
+	return nil
+}
+
+// Copy tags a local model under a second name via POST /api/copy.
+func (c *Client) Copy(ctx context.Context, source, destination string) error {
+	b, _ := json.Marshal(map[string]any{"source": source, "destination": destination})
+	res, err := c.do(ctx, http.MethodPost, "copy", b, false)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if err := statusError(res, "copy", source); err != nil {
+		return err
+	}
+	c.log.Info("ollama model copied", "source", source, "destination", destination)
+	return nil
+}
+
+// CreateRequest is the body of POST /api/create: a new model derived from an existing
+// one. ParseModelfile builds it from a Modelfile.
+type CreateRequest struct {
+	Model      string         `json:"model"`
+	From       string         `json:"from"`
+	System     string         `json:"system,omitempty"`
+	Template   string         `json:"template,omitempty"`
+	License    []string       `json:"license,omitempty"`
+	Parameters map[string]any `json:"parameters,omitempty"`
+	Messages   []ChatMessage  `json:"messages,omitempty"`
+	Quantize   string         `json:"quantize,omitempty"`
+}
+
+// Create builds a model via POST /api/create and waits for Ollama to finish.
+func (c *Client) Create(ctx context.Context, req CreateRequest) error {
+	payload := struct {
+		CreateRequest
+		Stream bool `json:"stream"`
+	}{req, false}
+	b, _ := json.Marshal(payload)
+	res, err := c.do(ctx, http.MethodPost, "create", b, false)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if err := statusError(res, "create", req.From); err != nil {
+		return err
+	}
+	var out struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return err
+	}
+	if out.Error != "" {
+		return fmt.Errorf("ollama create: %s", out.Error)
+	}
+	c.log.Info("ollama model created", "model", req.Model, "from", req.From)
+	return nil
+}
+
+// statusError turns an error response into an error, wrapping ErrModelNotFound on 404.
+func statusError(res *http.Response, op, model string) error {
+	if res.StatusCode < 400 {
+		return nil
+	}
+	body, _ := io.ReadAll(res.Body)
+	var e struct {
+		Error string `json:"error"`
+	}
+	msg := strings.TrimSpace(string(body))
+	if json.Unmarshal(body, &e) == nil && e.Error != "" {
+		msg = e.Error
+	}
+	if res.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("ollama %s: %w: %s", op, ErrModelNotFound, model)
+	}
+	return fmt.Errorf("ollama %s: %s", op, msg)
+}
+
 // create a second client with no timeout for long ops:
 var httpNoTimeout = &http.Client{Timeout: 0}
 
-// Pull downloads a model locally via POST /api/pull.
-func (c *Client) Pull(ctx context.Context, name string) error {
+// streamIdle bounds the wait for each chunk of a streamed generate/chat; the first one
+// includes loading the model. The stream as a whole runs as long as the caller's ctx.
+var streamIdle = 240 * time.Second
+
+// ErrStreamStalled is returned when a streamed generation sends nothing for streamIdle.
+var ErrStreamStalled = errors.New("stream stalled")
+
+// stream POSTs a streamed generate/chat. A long answer on CPU outlives any client
+// timeout, so it goes through httpNoTimeout with a per-request context that is
+// cancelled once Ollama sends nothing for streamIdle; closing the body releases it.
+func (c *Client) stream(ctx context.Context, endpoint string, body []byte) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	ib := &idleBody{cancel: cancel}
+	ib.timer = time.AfterFunc(streamIdle, func() {
+		ib.stalled.Store(true)
+		cancel()
+	})
+	res, err := c.send(ctx, httpNoTimeout, http.MethodPost, endpoint, body, false)
+	if err != nil {
+		ib.timer.Stop()
+		cancel()
+		if ib.stalled.Load() {
+			return nil, fmt.Errorf("ollama %s: %w", endpoint, ErrStreamStalled)
+		}
+		return nil, err
+	}
+	ib.ReadCloser = res.Body
+	res.Body = ib
+	return res, nil
+}
+
+// idleBody restarts the stream's idle timer on every read.
+type idleBody struct {
+	io.ReadCloser
+	timer   *time.Timer
+	cancel  context.CancelFunc
+	stalled atomic.Bool
+}
+
+func (b *idleBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.timer.Reset(streamIdle)
+	}
+	if err != nil && err != io.EOF && b.stalled.Load() {
+		err = ErrStreamStalled
+	}
+	return n, err
+}
+
+func (b *idleBody) Close() error {
+	b.timer.Stop()
+	b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// PullProgress is one NDJSON line of a streamed POST /api/pull, e.g.
+// {"status":"pulling 8934d96d3f08","digest":"sha256:…","total":3825819519,"completed":241970}.
+type PullProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+}
+
+// Pull downloads a model locally via POST /api/pull, streaming progress to onProgress
+// (which may be nil). A pull can take many minutes, so only ctx bounds it.
+func (c *Client) Pull(ctx context.Context, name string, onProgress func(PullProgress)) error {
 	if name == "" {
 		return errors.New("empty model name")
 	}
-	payload := map[string]any{"name": name, "stream": false}
+	payload := map[string]any{"name": name, "stream": true}
 	b, _ := json.Marshal(payload)
-	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/api/pull", c.baseURL), bytes.NewReader(b))
-	req.Header.Set("Content-Type", "application/json")
-	res, err := c.client.Do(req)
+	res, err := c.do(ctx, http.MethodPost, "pull", b, false)
 	if err != nil {
 		return err
 	}
-
-	body, _ := io.ReadAll(res.Body)
-	c.log.Info("ollama pull response", "res", string(body))
-
 	defer res.Body.Close()
 	if res.StatusCode >= 400 {
+		body, _ := io.ReadAll(res.Body)
 		return fmt.Errorf("ollama pull: %s", string(body))
 	}
-	return nil
-}
-
-func trimSlash(s string) string {
-	if len(s) > 0 && s[len(s)-1] == '/' {
-		return s[:len(s)-1]
+	dec := json.NewDecoder(res.Body)
+	for {
+		var line struct {
+			PullProgress
+			Error string `json:"error"`
+		}
+		if err := dec.Decode(&line); err != nil {
+			if errors.Is(err, io.EOF) {
+				return errors.New("ollama pull: stream ended before success")
+			}
+			return err
+		}
+		if line.Error != "" {
+			return fmt.Errorf("ollama pull: %s", line.Error)
+		}
+		if onProgress != nil {
+			onProgress(line.PullProgress)
+		}
+		if line.Status == "success" {
+			c.log.Info("ollama pull done", "model", name)
+			return nil
+		}
 	}
-	return s
 }
@@ -0,0 +1,55 @@
+package ollama
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/varsilias/zero-downtime/pkg/types"
+)
+
+// TestStreamIdle checks that a streamed chat runs for as long as chunks keep coming,
+// well past the idle limit in total, and gives up once they stop.
+func TestStreamIdle(t *testing.T) {
+	defer func(d time.Duration) { streamIdle = d }(streamIdle)
+	streamIdle = 100 * time.Millisecond
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stall := r.URL.Path == "/api/generate"
+		for i := 0; i < 6; i++ {
+			fmt.Fprintf(w, `{"message":{"content":"%d"},"response":"%d"}`+"\n", i, i)
+			w.(http.Flusher).Flush()
+			wait := 40 * time.Millisecond
+			if stall && i == 1 {
+				wait = time.Second
+			}
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(wait):
+			}
+		}
+		fmt.Fprint(w, `{"done":true}`+"\n")
+	}))
+	defer srv.Close()
+	c := NewClient(srv.URL, slog.New(slog.NewTextHandler(io.Discard, nil)), Resilience{}, nil)
+
+	text, took, err := c.ChatStream(context.Background(), "m", []ChatMessage{{Role: "user", Content: "hi"}}, types.GenerateOptions{}, nil)
+	if err != nil || text != "012345" {
+		t.Fatalf("ChatStream = %q, %v", text, err)
+	}
+	if took < 2*streamIdle {
+		t.Fatalf("stream took %v, want it to outlive the idle limit", took)
+	}
+
+	text, _, err = c.GenerateStream(context.Background(), "m", "hi", types.GenerateOptions{}, nil)
+	if !errors.Is(err, ErrStreamStalled) || text != "01" {
+		t.Fatalf("stalled GenerateStream = %q, %v; want \"01\", ErrStreamStalled", text, err)
+	}
+}
@@ -0,0 +1,89 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// KeepAlive is how long Ollama keeps a model in memory after a call, per model; "*" is
+// the default for models without an entry. A negative duration keeps the model loaded
+// until it is unloaded, zero unloads it right after each call. Models without an entry
+// (and no "*") get Ollama's own default of 5m.
+type KeepAlive map[string]time.Duration
+
+// ParseKeepAlive reads one keep_alive value: a Go duration ("30m") or "-1" for forever.
+func ParseKeepAlive(s string) (time.Duration, error) {
+	if s == "-1" {
+		return -1, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("keep_alive %q: want a duration like 30m, or -1", s)
+	}
+	return d, nil
+}
+
+// value is the keep_alive field for model, if one is configured.
+func (k KeepAlive) value(model string) (any, bool) {
+	d, ok := k[model]
+	if !ok {
+		if d, ok = k["*"]; !ok {
+			return nil, false
+		}
+	}
+	if d < 0 {
+		return -1, true // Ollama only takes "forever" as a negative number, not "-1s"
+	}
+	return d.String(), true
+}
+
+// withKeepAlive adds the configured keep_alive for payload["model"] to a generate/chat payload.
+func (c *Client) withKeepAlive(payload map[string]any) map[string]any {
+	model, _ := payload["model"].(string)
+	if v, ok := c.keepAlive.value(model); ok {
+		payload["keep_alive"] = v
+	}
+	return payload
+}
+
+// Load brings a model into memory without generating anything (POST /api/generate
+// with no prompt), so the first real prompt does not pay for the load. It returns
+// how long the load took.
+func (c *Client) Load(ctx context.Context, model string) (time.Duration, error) {
+	payload := c.withKeepAlive(map[string]any{"model": model, "stream": false})
+	start := time.Now()
+	if err := c.residency(ctx, "load", payload); err != nil {
+		return 0, err
+	}
+	c.log.Info("ollama model loaded", "model", model, "took", time.Since(start).Round(time.Millisecond))
+	return time.Since(start), nil
+}
+
+// Unload evicts a model from memory right away (keep_alive 0). Unloading a model that
+// is not loaded is a no-op.
+func (c *Client) Unload(ctx context.Context, model string) error {
+	payload := map[string]any{"model": model, "keep_alive": 0, "stream": false}
+	if err := c.residency(ctx, "unload", payload); err != nil {
+		return err
+	}
+	c.log.Info("ollama model unloaded", "model", model)
+	return nil
+}
+
+func (c *Client) residency(ctx context.Context, op string, payload map[string]any) error {
+	b, _ := json.Marshal(payload)
+	res, err := c.do(ctx, http.MethodPost, "generate", b, false)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if err := statusError(res, op, payload["model"].(string)); err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, res.Body)
+	return nil
+}
@@ -0,0 +1,179 @@
+package ollama
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidName is wrapped by ValidName's errors.
+var ErrInvalidName = errors.New("invalid model name")
+
+// nameRE is [registry/][namespace/]model[:tag]; each part starts with a letter or digit.
+var nameRE = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*(/[A-Za-z0-9][A-Za-z0-9._-]*){0,2}(:[A-Za-z0-9_][A-Za-z0-9._-]{0,127})?$`)
+
+// ValidName checks that name is a model reference Ollama accepts.
+func ValidName(name string) error {
+	if name == "" {
+		return fmt.Errorf("%w: empty", ErrInvalidName)
+	}
+	if len(name) > 350 || !nameRE.MatchString(name) || strings.Contains(name, "..") {
+		return fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+	return nil
+}
+
+// MaxModelfile is the largest Modelfile ParseModelfile accepts, in bytes.
+const MaxModelfile = 64 << 10
+
+// ModelfileError reports a Modelfile that cannot be turned into a CreateRequest.
+type ModelfileError struct {
+	Line int // 1-based; 0 for the file as a whole
+	Msg  string
+}
+
+func (e *ModelfileError) Error() string {
+	if e.Line == 0 {
+		return "modelfile: " + e.Msg
+	}
+	return fmt.Sprintf("modelfile line %d: %s", e.Line, e.Msg)
+}
+
+// ParseModelfile turns a Modelfile into the CreateRequest for model name. Only
+// instructions that derive from a model Ollama already has are supported: FROM <model>,
+// PARAMETER, TEMPLATE, SYSTEM, LICENSE and MESSAGE. ADAPTER and FROM with a file path
+// need files on the Ollama host and are rejected. Values may be "quoted" or span lines
+// in """triple quotes""".
+func ParseModelfile(name, modelfile string) (CreateRequest, error) {
+	req := CreateRequest{Model: name}
+	if len(modelfile) > MaxModelfile {
+		return req, &ModelfileError{Msg: fmt.Sprintf("larger than %d bytes", MaxModelfile)}
+	}
+	var stops []string
+	sc := bufio.NewScanner(strings.NewReader(modelfile))
+	sc.Buffer(make([]byte, 0, 4096), MaxModelfile)
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		cmd, rest, _ := strings.Cut(text, " ")
+		rest = strings.TrimSpace(rest)
+		start := line
+		// a """ value runs until the closing """, possibly lines later
+		if strings.HasPrefix(rest, `"""`) {
+			body := strings.TrimPrefix(rest, `"""`)
+			for !strings.Contains(body, `"""`) {
+				if !sc.Scan() {
+					return req, &ModelfileError{Line: start, Msg: `unterminated """`}
+				}
+				line++
+				body += "\n" + sc.Text()
+			}
+			body, tail, _ := strings.Cut(body, `"""`)
+			if strings.TrimSpace(tail) != "" {
+				return req, &ModelfileError{Line: line, Msg: `unexpected text after closing """`}
+			}
+			rest = body
+		} else {
+			rest = unquote(rest)
+		}
+		if err := apply(&req, &stops, strings.ToUpper(cmd), rest); err != nil {
+			return req, &ModelfileError{Line: start, Msg: err.Error()}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return req, &ModelfileError{Msg: err.Error()}
+	}
+	if req.From == "" {
+		return req, &ModelfileError{Msg: "missing FROM"}
+	}
+	if len(stops) > 0 {
+		if req.Parameters == nil {
+			req.Parameters = map[string]any{}
+		}
+		req.Parameters["stop"] = stops
+	}
+	return req, nil
+}
+
+// apply adds one instruction to req; stop sequences are collected separately because
+// PARAMETER stop may repeat.
+func apply(req *CreateRequest, stops *[]string, cmd, value string) error {
+	switch cmd {
+	case "FROM":
+		if req.From != "" {
+			return errors.New("FROM given twice")
+		}
+		if strings.HasPrefix(value, ".") || strings.HasPrefix(value, "/") || strings.HasPrefix(value, "~") {
+			return errors.New("FROM must name a model, not a file path")
+		}
+		if err := ValidName(value); err != nil {
+			return err
+		}
+		req.From = value
+	case "PARAMETER":
+		key, raw, ok := strings.Cut(value, " ")
+		raw = unquote(strings.TrimSpace(raw))
+		if !ok || key == "" || raw == "" {
+			return errors.New("PARAMETER needs a name and a value")
+		}
+		key = strings.ToLower(key)
+		if key == "stop" {
+			*stops = append(*stops, raw)
+			return nil
+		}
+		if req.Parameters == nil {
+			req.Parameters = map[string]any{}
+		}
+		req.Parameters[key] = paramValue(raw)
+	case "TEMPLATE":
+		req.Template = value
+	case "SYSTEM":
+		req.System = value
+	case "LICENSE":
+		req.License = append(req.License, value)
+	case "MESSAGE":
+		role, content, ok := strings.Cut(value, " ")
+		if !ok {
+			return errors.New("MESSAGE needs a role and content")
+		}
+		switch role = strings.ToLower(role); role {
+		case "system", "user", "assistant":
+		default:
+			return fmt.Errorf("MESSAGE role %q (want system, user or assistant)", role)
+		}
+		req.Messages = append(req.Messages, ChatMessage{Role: role, Content: unquote(strings.TrimSpace(content))})
+	case "ADAPTER":
+		return errors.New("ADAPTER is not supported (it needs a file on the Ollama host)")
+	default:
+		return fmt.Errorf("unknown instruction %q", cmd)
+	}
+	return nil
+}
+
+// paramValue types a PARAMETER value the way Ollama expects: integer, float, bool or string.
+func paramValue(raw string) any {
+	if n, err := strconv.Atoi(raw); err == nil {
+		return n
+	}
+	if f, err := strconv.ParseFloat(raw, 64); err == nil {
+		return f
+	}
+	if b, err := strconv.ParseBool(raw); err == nil {
+		return b
+	}
+	return raw
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
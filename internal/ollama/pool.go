@@ -0,0 +1,522 @@
+package ollama
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/varsilias/zero-downtime/pkg/types"
+	"log/slog"
+	"net"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrNoEndpoint is returned by a Pool when none of its instances is healthy.
+var ErrNoEndpoint = errors.New("no healthy ollama endpoint")
+
+// Balance is how a Pool picks an instance for a generation.
+type Balance string
+
+const (
+	// BalanceAffinity prefers instances that already have the model loaded (per
+	// /api/ps), then the least busy one.
+	BalanceAffinity Balance = "affinity"
+	// BalanceLeastInFlight picks the instance with the fewest calls in flight.
+	BalanceLeastInFlight Balance = "least-inflight"
+)
+
+// ParseBalance accepts affinity or least-inflight; empty means affinity.
+func ParseBalance(s string) (Balance, error) {
+	switch b := Balance(s); b {
+	case "":
+		return BalanceAffinity, nil
+	case BalanceAffinity, BalanceLeastInFlight:
+		return b, nil
+	default:
+		return "", fmt.Errorf("unknown balance %q (want affinity or least-inflight)", s)
+	}
+}
+
+// Pool spreads calls over several Ollama instances. Every instance is health-checked
+// in the background (/api/version, plus /api/ps for the loaded models); failing ones
+// leave the pool until a check succeeds again. A call that cannot reach its instance
+// takes it out at once and moves on to the next one.
+type Pool struct {
+	members []*member
+	balance Balance
+	log     *slog.Logger
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type member struct {
+	c *Client
+
+	mu       sync.Mutex
+	healthy  bool
+	inFlight int
+	loaded   map[string]bool
+	lastErr  string
+	checked  time.Time
+}
+
+// NewPool checks every instance once, then again every interval (default 10s) until Close.
+func NewPool(baseURLs []string, log *slog.Logger, res Resilience, keepAlive KeepAlive, balance Balance, interval time.Duration) *Pool {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	p := &Pool{balance: balance, log: log, stop: make(chan struct{}), done: make(chan struct{})}
+	for _, u := range baseURLs {
+		p.members = append(p.members, &member{c: NewClient(u, log.With("ollama", u), res, keepAlive)})
+	}
+	p.checkAll(interval)
+	go p.monitor(interval)
+	return p
+}
+
+func (p *Pool) monitor(every time.Duration) {
+	defer close(p.done)
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-t.C:
+			p.checkAll(every)
+		}
+	}
+}
+
+// checkAll health-checks every instance, bounded by timeout.
+func (p *Pool) checkAll(timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	p.checkEach(ctx)
+}
+
+// checkEach health-checks every instance concurrently.
+func (p *Pool) checkEach(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, m := range p.members {
+		wg.Add(1)
+		go func(m *member) {
+			defer wg.Done()
+			p.check(ctx, m)
+		}(m)
+	}
+	wg.Wait()
+}
+
+func (p *Pool) check(ctx context.Context, m *member) {
+	err := m.c.Ping(ctx)
+	var loaded map[string]bool
+	if err == nil {
+		running, psErr := m.c.PS(ctx)
+		if psErr != nil {
+			// older Ollama without /api/ps is still usable, just without affinity
+			p.log.Debug("ollama ps", "url", m.c.baseURL, "err", psErr)
+		}
+		loaded = map[string]bool{}
+		for _, r := range running {
+			loaded[r.Name] = true
+		}
+	}
+	m.mu.Lock()
+	m.loaded, m.checked = loaded, time.Now()
+	m.mu.Unlock()
+	p.mark(m, err)
+}
+
+// mark records an instance as healthy (err == nil) or drops it from the pool.
+func (p *Pool) mark(m *member, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	up := err == nil
+	if err != nil {
+		m.lastErr = err.Error()
+	} else {
+		m.lastErr = ""
+	}
+	if up == m.healthy {
+		return
+	}
+	m.healthy = up
+	if up {
+		p.log.Info("ollama endpoint joined the pool", "url", m.c.baseURL)
+	} else {
+		p.log.Warn("ollama endpoint dropped from the pool", "url", m.c.baseURL, "err", err)
+	}
+}
+
+func (p *Pool) healthy() []*member {
+	var out []*member
+	for _, m := range p.members {
+		m.mu.Lock()
+		if m.healthy {
+			out = append(out, m)
+		}
+		m.mu.Unlock()
+	}
+	return out
+}
+
+// pick chooses the instance for model among the healthy ones not in skip.
+func (p *Pool) pick(model string, skip map[*member]bool) (*member, error) {
+	var (
+		best      *member
+		bestScore [2]int // (not loaded, in flight): lower wins
+	)
+	for _, m := range p.healthy() {
+		if skip[m] {
+			continue
+		}
+		m.mu.Lock()
+		score := [2]int{1, m.inFlight}
+		if p.balance == BalanceAffinity && m.loaded[model] {
+			score[0] = 0
+		}
+		m.mu.Unlock()
+		if best == nil || score[0] < bestScore[0] || (score[0] == bestScore[0] && score[1] < bestScore[1]) {
+			best, bestScore = m, score
+		}
+	}
+	if best == nil {
+		return nil, ErrNoEndpoint
+	}
+	return best, nil
+}
+
+// call runs fn on a picked instance, moving on to the next one when the instance
+// cannot be reached and nothing has been streamed yet.
+func (p *Pool) call(ctx context.Context, model string, onToken func(string) error, fn func(c *Client, onToken func(string) error) (string, time.Duration, error)) (string, time.Duration, error) {
+	tried := map[*member]bool{}
+	for {
+		m, err := p.pick(model, tried)
+		if err != nil {
+			return "", 0, err
+		}
+		tried[m] = true
+		streamed := false
+		tracked := onToken
+		if onToken != nil {
+			tracked = func(token string) error {
+				streamed = true
+				return onToken(token)
+			}
+		}
+		m.mu.Lock()
+		m.inFlight++
+		m.mu.Unlock()
+		text, latency, err := fn(m.c, tracked)
+		m.mu.Lock()
+		m.inFlight--
+		if err == nil {
+			// the instance has the model loaded now
+			if m.loaded == nil {
+				m.loaded = map[string]bool{}
+			}
+			m.loaded[model] = true
+		}
+		m.mu.Unlock()
+		if err == nil || streamed || ctx.Err() != nil || !Unreachable(err) {
+			return text, latency, err
+		}
+		p.mark(m, err)
+	}
+}
+
+// Unreachable reports whether err means a backend could not be talked to at all: a
+// failed dial (refused, no route, unknown host), an open circuit breaker or an empty
+// pool. Timeouts and cancellation are not outages: a slow generation hitting the client
+// timeout says nothing about whether the instance is up. The pool and chat.Failover
+// both route around a backend on this.
+func Unreachable(err error) bool {
+	if errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrNoEndpoint) {
+		return true
+	}
+	var te interface{ Timeout() bool }
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) || errors.As(err, &te) && te.Timeout() {
+		return false
+	}
+	var ne *net.OpError
+	return errors.As(err, &ne) && ne.Op == "dial" || errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// Ping checks every instance now and succeeds when at least one is healthy.
+func (p *Pool) Ping(ctx context.Context) error {
+	p.checkEach(ctx)
+	if len(p.healthy()) == 0 {
+		return ErrNoEndpoint
+	}
+	return nil
+}
+
+func (p *Pool) Generate(ctx context.Context, model, prompt string, opts types.GenerateOptions) (string, time.Duration, error) {
+	return p.call(ctx, model, nil, func(c *Client, _ func(string) error) (string, time.Duration, error) {
+		return c.Generate(ctx, model, prompt, opts)
+	})
+}
+
+func (p *Pool) GenerateStream(ctx context.Context, model, prompt string, opts types.GenerateOptions, onToken func(string) error) (string, time.Duration, error) {
+	return p.call(ctx, model, onToken, func(c *Client, onToken func(string) error) (string, time.Duration, error) {
+		return c.GenerateStream(ctx, model, prompt, opts, onToken)
+	})
+}
+
+func (p *Pool) Chat(ctx context.Context, model string, messages []ChatMessage, opts types.GenerateOptions) (string, time.Duration, error) {
+	return p.call(ctx, model, nil, func(c *Client, _ func(string) error) (string, time.Duration, error) {
+		return c.Chat(ctx, model, messages, opts)
+	})
+}
+
+func (p *Pool) ChatStream(ctx context.Context, model string, messages []ChatMessage, opts types.GenerateOptions, onToken func(string) error) (string, time.Duration, error) {
+	return p.call(ctx, model, onToken, func(c *Client, onToken func(string) error) (string, time.Duration, error) {
+		return c.ChatStream(ctx, model, messages, opts, onToken)
+	})
+}
+
+// Tags lists the models of every healthy instance, each name once.
+func (p *Pool) Tags(ctx context.Context) ([]TagModel, error) {
+	members := p.healthy()
+	if len(members) == 0 {
+		return nil, ErrNoEndpoint
+	}
+	var (
+		out  []TagModel
+		seen = map[string]bool{}
+		errs []error
+	)
+	for _, m := range members {
+		tags, err := m.c.Tags(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, t := range tags {
+			if !seen[t.Name] {
+				seen[t.Name] = true
+				out = append(out, t)
+			}
+		}
+	}
+	if len(errs) == len(members) {
+		return nil, errors.Join(errs...)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// Show describes name from the instance a generation for it would go to.
+func (p *Pool) Show(ctx context.Context, name string) (ModelInfo, error) {
+	m, err := p.pick(name, nil)
+	if err != nil {
+		return ModelInfo{}, err
+	}
+	return m.c.Show(ctx, name)
+}
+
+// Pull downloads name on every healthy instance, so any of them can serve it. Progress
+// digests are prefixed with the instance URL, so layers of different instances add up.
+func (p *Pool) Pull(ctx context.Context, name string, onProgress func(PullProgress)) error {
+	members := p.healthy()
+	if len(members) == 0 {
+		return ErrNoEndpoint
+	}
+	errs := make([]error, len(members))
+	var wg sync.WaitGroup
+	for i, m := range members {
+		wg.Add(1)
+		go func(i int, m *member) {
+			defer wg.Done()
+			var progress func(PullProgress)
+			if onProgress != nil {
+				progress = func(pp PullProgress) {
+					if pp.Digest != "" {
+						pp.Digest = m.c.baseURL + "/" + pp.Digest
+					}
+					onProgress(pp)
+				}
+			}
+			if err := m.c.Pull(ctx, name, progress); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", m.c.baseURL, err)
+			}
+		}(i, m)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// each runs fn on every healthy instance at once. Instances that do not have the model
+// are skipped; ErrModelNotFound is returned only when none of them has it.
+func (p *Pool) each(ctx context.Context, fn func(m *member) error) error {
+	members := p.healthy()
+	if len(members) == 0 {
+		return ErrNoEndpoint
+	}
+	errs := make([]error, len(members))
+	var wg sync.WaitGroup
+	for i, m := range members {
+		wg.Add(1)
+		go func(i int, m *member) {
+			defer wg.Done()
+			if err := fn(m); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", m.c.baseURL, err)
+			}
+		}(i, m)
+	}
+	wg.Wait()
+	var failed []error
+	missing := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrModelNotFound):
+			missing++
+		default:
+			failed = append(failed, err)
+		}
+	}
+	if missing == len(members) {
+		return errs[0]
+	}
+	return errors.Join(failed...)
+}
+
+// Delete removes name from every healthy instance.
+func (p *Pool) Delete(ctx context.Context, name string) error {
+	return p.each(ctx, func(m *member) error { return m.c.Delete(ctx, name) })
+}
+
+// Copy tags source as destination on every healthy instance that has it.
+func (p *Pool) Copy(ctx context.Context, source, destination string) error {
+	return p.each(ctx, func(m *member) error { return m.c.Copy(ctx, source, destination) })
+}
+
+// Create builds the model on every healthy instance that has its base model.
+func (p *Pool) Create(ctx context.Context, req CreateRequest) error {
+	return p.each(ctx, func(m *member) error { return m.c.Create(ctx, req) })
+}
+
+// PS lists the models loaded on every healthy instance, each with its Endpoint.
+func (p *Pool) PS(ctx context.Context) ([]RunningModel, error) {
+	members := p.healthy()
+	if len(members) == 0 {
+		return nil, ErrNoEndpoint
+	}
+	var (
+		out  []RunningModel
+		errs []error
+	)
+	for _, m := range members {
+		running, err := m.c.PS(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", m.c.baseURL, err))
+			continue
+		}
+		loaded := map[string]bool{}
+		for _, r := range running {
+			r.Endpoint = m.c.baseURL
+			out = append(out, r)
+			loaded[r.Name] = true
+		}
+		m.mu.Lock()
+		m.loaded = loaded
+		m.mu.Unlock()
+	}
+	if len(errs) == len(members) {
+		return nil, errors.Join(errs...)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		return out[i].Endpoint < out[j].Endpoint
+	})
+	return out, nil
+}
+
+// Load loads model on every healthy instance, so the first prompt is fast whichever
+// one it goes to. It returns the slowest load.
+func (p *Pool) Load(ctx context.Context, model string) (time.Duration, error) {
+	var (
+		mu      sync.Mutex
+		slowest time.Duration
+	)
+	err := p.each(ctx, func(m *member) error {
+		took, err := m.c.Load(ctx, model)
+		if err != nil {
+			return err
+		}
+		m.mu.Lock()
+		if m.loaded == nil {
+			m.loaded = map[string]bool{}
+		}
+		m.loaded[model] = true
+		m.mu.Unlock()
+		mu.Lock()
+		slowest = max(slowest, took)
+		mu.Unlock()
+		return nil
+	})
+	return slowest, err
+}
+
+// Unload evicts model from every healthy instance.
+func (p *Pool) Unload(ctx context.Context, model string) error {
+	return p.each(ctx, func(m *member) error {
+		if err := m.c.Unload(ctx, model); err != nil {
+			return err
+		}
+		m.mu.Lock()
+		delete(m.loaded, model)
+		m.mu.Unlock()
+		return nil
+	})
+}
+
+// Circuits reports the breakers of every instance.
+func (p *Pool) Circuits() []CircuitStatus {
+	var out []CircuitStatus
+	for _, m := range p.members {
+		out = append(out, m.c.Circuits()...)
+	}
+	return out
+}
+
+// EndpointStatus is the pool's view of one instance.
+type EndpointStatus struct {
+	URL      string    `json:"url"`
+	Healthy  bool      `json:"healthy"`
+	InFlight int       `json:"in_flight"`
+	Loaded   []string  `json:"loaded"`
+	Error    string    `json:"error,omitempty"`
+	Checked  time.Time `json:"checked"`
+}
+
+// Endpoints reports every instance, healthy or not.
+func (p *Pool) Endpoints() []EndpointStatus {
+	out := make([]EndpointStatus, 0, len(p.members))
+	for _, m := range p.members {
+		m.mu.Lock()
+		s := EndpointStatus{URL: m.c.baseURL, Healthy: m.healthy, InFlight: m.inFlight, Loaded: []string{}, Error: m.lastErr, Checked: m.checked}
+		for name := range m.loaded {
+			s.Loaded = append(s.Loaded, name)
+		}
+		m.mu.Unlock()
+		sort.Strings(s.Loaded)
+		out = append(out, s)
+	}
+	return out
+}
+
+// Close stops the background health checks and waits for them to exit.
+func (p *Pool) Close() error {
+	p.closeOnce.Do(func() { close(p.stop) })
+	<-p.done
+	return nil
+}
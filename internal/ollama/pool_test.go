@@ -0,0 +1,251 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/varsilias/zero-downtime/pkg/types"
+)
+
+func TestUnreachable(t *testing.T) {
+	// a port nothing listens on
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := "http://" + l.Addr().String()
+	l.Close()
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer slow.Close()
+
+	get := func(ctx context.Context, c *http.Client, url string) error {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		res, err := c.Do(req)
+		if err == nil {
+			res.Body.Close()
+		}
+		return err
+	}
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	short, cancelShort := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancelShort()
+
+	for _, tc := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"connection refused", get(context.Background(), http.DefaultClient, closed), true},
+		{"circuit open", fmt.Errorf("generate: %w", ErrCircuitOpen), true},
+		{"empty pool", ErrNoEndpoint, true},
+		{"client timeout", get(context.Background(), &http.Client{Timeout: 20 * time.Millisecond}, slow.URL), false},
+		{"context deadline", get(short, http.DefaultClient, slow.URL), false},
+		{"canceled", get(canceled, http.DefaultClient, slow.URL), false},
+		{"backend error", errors.New("ollama generate: 404 model not found"), false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.err == nil {
+				t.Fatal("no error to classify")
+			}
+			if got := Unreachable(tc.err); got != tc.want {
+				t.Fatalf("Unreachable(%v) = %v, want %v", tc.err, got, tc.want)
+			}
+		})
+	}
+}
+
+// backend is a fake Ollama instance that answers every chat with its own name. A chat
+// for model "slow" waits until release is closed; /api/version fails while down is set.
+type backend struct {
+	name    string
+	srv     *httptest.Server
+	loaded  []string
+	down    atomic.Bool
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBackend(t *testing.T, name string, loaded ...string) *backend {
+	b := &backend{name: name, loaded: loaded, started: make(chan struct{}, 1), release: make(chan struct{})}
+	b.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/version":
+			if b.down.Load() {
+				http.Error(w, "down", http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprint(w, `{"version":"0.0.0"}`)
+		case "/api/ps":
+			var ps struct {
+				Models []RunningModel `json:"models"`
+			}
+			for _, name := range b.loaded {
+				ps.Models = append(ps.Models, RunningModel{Name: name})
+			}
+			json.NewEncoder(w).Encode(ps)
+		case "/api/chat":
+			var req struct {
+				Model string `json:"model"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.Model == "slow" {
+				b.started <- struct{}{}
+				select {
+				case <-b.release:
+				case <-r.Context().Done():
+					return
+				}
+			}
+			fmt.Fprintf(w, `{"message":{"role":"assistant","content":%q}}`, b.name)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(b.srv.Close)
+	return b
+}
+
+func newTestPool(t *testing.T, balance Balance, interval time.Duration, backends ...*backend) *Pool {
+	var urls []string
+	for _, b := range backends {
+		urls = append(urls, b.srv.URL)
+	}
+	p := NewPool(urls, slog.New(slog.NewTextHandler(io.Discard, nil)), Resilience{}, nil, balance, interval)
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+// chatWith returns the name of the backend that answered.
+func chatWith(p *Pool, model string) (string, error) {
+	text, _, err := p.Chat(context.Background(), model, []ChatMessage{{Role: "user", Content: "hi"}}, types.GenerateOptions{})
+	return text, err
+}
+
+func expectBackend(t *testing.T, p *Pool, model, want string) {
+	t.Helper()
+	got, err := chatWith(p, model)
+	if err != nil || got != want {
+		t.Fatalf("chat %s went to %q (%v), want %q", model, got, err, want)
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPoolAffinity(t *testing.T) {
+	a, b := newBackend(t, "a"), newBackend(t, "b", "gemma")
+
+	p := newTestPool(t, BalanceAffinity, time.Hour, a, b)
+	expectBackend(t, p, "gemma", "b") // loaded there
+	expectBackend(t, p, "other", "a") // loaded nowhere: first of the idle ones
+
+	p = newTestPool(t, BalanceLeastInFlight, time.Hour, a, b)
+	expectBackend(t, p, "gemma", "a") // /api/ps does not count
+}
+
+// TestPoolInFlight keeps a call running on a and checks where the next one goes: to the
+// busy instance that has the model loaded under affinity, to the idle one otherwise.
+func TestPoolInFlight(t *testing.T) {
+	for _, tc := range []struct {
+		balance Balance
+		want    string
+	}{
+		{BalanceAffinity, "a"},
+		{BalanceLeastInFlight, "b"},
+	} {
+		t.Run(string(tc.balance), func(t *testing.T) {
+			a, b := newBackend(t, "a", "gemma"), newBackend(t, "b")
+			p := newTestPool(t, tc.balance, time.Hour, a, b)
+
+			slow := make(chan string, 1)
+			go func() {
+				name, _ := chatWith(p, "slow")
+				slow <- name
+			}()
+			<-a.started
+			if got := p.Endpoints()[0].InFlight; got != 1 {
+				t.Fatalf("a has %d calls in flight, want 1", got)
+			}
+			expectBackend(t, p, "gemma", tc.want)
+			close(a.release)
+			if got := <-slow; got != "a" {
+				t.Fatalf("slow chat went to %q, want a", got)
+			}
+			if got := p.Endpoints()[0].InFlight; got != 0 {
+				t.Fatalf("a has %d calls in flight after they finished, want 0", got)
+			}
+		})
+	}
+}
+
+func TestPoolSkipsDeadInstance(t *testing.T) {
+	a, b := newBackend(t, "a"), newBackend(t, "b")
+	p := newTestPool(t, BalanceLeastInFlight, time.Hour, a, b)
+
+	// a is picked first, refuses the connection and is dropped; b answers instead
+	a.srv.Close()
+	p.members[0].c.client.CloseIdleConnections()
+	expectBackend(t, p, "m", "b")
+	if s := p.Endpoints()[0]; s.Healthy || s.Error == "" {
+		t.Fatalf("a after a refused call: %+v, want unhealthy with an error", s)
+	}
+	expectBackend(t, p, "m", "b")
+
+	b.srv.Close()
+	p.members[1].c.client.CloseIdleConnections()
+	if _, err := chatWith(p, "m"); !errors.Is(err, ErrNoEndpoint) {
+		t.Fatalf("chat with every instance down: %v, want ErrNoEndpoint", err)
+	}
+	if err := p.Ping(context.Background()); !errors.Is(err, ErrNoEndpoint) {
+		t.Fatalf("Ping with every instance down: %v, want ErrNoEndpoint", err)
+	}
+}
+
+// TestPoolHealthChecks checks that the background checks drop an instance whose
+// /api/version fails and take it back once it answers again.
+func TestPoolHealthChecks(t *testing.T) {
+	a, b := newBackend(t, "a", "gemma"), newBackend(t, "b")
+	a.down.Store(true)
+	p := newTestPool(t, BalanceAffinity, 20*time.Millisecond, a, b)
+
+	if s := p.Endpoints()[0]; s.Healthy || s.Error == "" {
+		t.Fatalf("a failing its first check: %+v, want unhealthy with an error", s)
+	}
+	expectBackend(t, p, "gemma", "b")
+
+	a.down.Store(false)
+	waitFor(t, "a to rejoin", func() bool { return p.Endpoints()[0].Healthy })
+	if s := p.Endpoints()[0]; s.Error != "" || len(s.Loaded) != 1 || s.Loaded[0] != "gemma" {
+		t.Fatalf("a after rejoining: %+v", s)
+	}
+	expectBackend(t, p, "gemma", "a")
+
+	a.down.Store(true)
+	waitFor(t, "a to be dropped", func() bool { return !p.Endpoints()[0].Healthy })
+	expectBackend(t, p, "gemma", "b")
+}
@@ -0,0 +1,271 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/varsilias/zero-downtime/pkg/types"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Client talks to any server implementing the OpenAI chat API (OpenAI, vLLM,
+// llama.cpp's server, LiteLLM, …). baseURL includes the version prefix, e.g.
+// http://vllm:8000/v1.
+type Client struct {
+	baseURL string
+	apiKey  string
+	headers map[string]string
+	log     *slog.Logger
+	client  *http.Client
+	stream  *http.Client // no overall timeout: streams are bounded by streamIdle instead
+}
+
+// streamIdle bounds the wait for each SSE line of a streamed chat; the first one may
+// include the upstream loading the model. The stream as a whole runs as long as ctx.
+var streamIdle = 240 * time.Second
+
+// ErrStreamStalled is returned when a streamed chat sends nothing for streamIdle.
+var ErrStreamStalled = errors.New("openai chat: stream stalled")
+
+type Model struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// NewClient returns a client for baseURL. apiKey is sent as a bearer token when set;
+// headers are added to every request (e.g. an organisation or routing header).
+func NewClient(baseURL, apiKey string, headers map[string]string, log *slog.Logger) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		headers: headers,
+		log:     log,
+		client:  &http.Client{Timeout: 240 * time.Second},
+		stream:  &http.Client{},
+	}
+}
+
+func (c *Client) newRequest(ctx context.Context, method, path string, body any) (*http.Request, error) {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, r)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+	return req, nil
+}
+
+// do sends req and turns error statuses into errors carrying the upstream message.
+func (c *Client) do(req *http.Request, op string) (*http.Response, error) {
+	return c.send(c.client, req, op)
+}
+
+func (c *Client) send(hc *http.Client, req *http.Request, op string) (*http.Response, error) {
+	res, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 400 {
+		defer res.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+		var e struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(body, &e) == nil && e.Error.Message != "" {
+			return nil, fmt.Errorf("openai %s: %s: %s", op, res.Status, e.Error.Message)
+		}
+		return nil, fmt.Errorf("openai %s: %s: %s", op, res.Status, strings.TrimSpace(string(body)))
+	}
+	return res, nil
+}
+
+// Ping checks that the server answers GET /models (with our credentials).
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.Models(ctx)
+	return err
+}
+
+// Models lists the served models via GET /models.
+func (c *Client) Models(ctx context.Context) ([]Model, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, "/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.do(req, "models")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	var out struct {
+		Data []Model `json:"data"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return out.Data, nil
+}
+
+// ChatMessage is one turn of a conversation sent to /chat/completions.
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// chatPayload maps our options onto the OpenAI request fields.
+func chatPayload(model string, messages []ChatMessage, opts types.GenerateOptions, stream bool) map[string]any {
+	p := map[string]any{"model": model, "messages": messages, "stream": stream}
+	if opts.Temperature != nil {
+		p["temperature"] = *opts.Temperature
+	}
+	if opts.TopP != nil {
+		p["top_p"] = *opts.TopP
+	}
+	// -1/-2 (until done / context full) are Ollama-only: leave max_tokens unset
+	if opts.NumPredict != nil && *opts.NumPredict > 0 {
+		p["max_tokens"] = *opts.NumPredict
+	}
+	if opts.Seed != nil {
+		p["seed"] = *opts.Seed
+	}
+	if len(opts.Stop) > 0 {
+		p["stop"] = opts.Stop
+	}
+	return p
+}
+
+// Chat sends the whole conversation (non-stream) via /chat/completions.
+func (c *Client) Chat(ctx context.Context, model string, messages []ChatMessage, opts types.GenerateOptions) (string, time.Duration, error) {
+	req, err := c.newRequest(ctx, http.MethodPost, "/chat/completions", chatPayload(model, messages, opts, false))
+	if err != nil {
+		return "", 0, err
+	}
+	start := time.Now()
+	res, err := c.do(req, "chat")
+	if err != nil {
+		return "", 0, err
+	}
+	defer res.Body.Close()
+	var out struct {
+		Choices []struct {
+			Message ChatMessage `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return "", 0, err
+	}
+	if len(out.Choices) == 0 {
+		return "", 0, errors.New("openai chat: no choices in response")
+	}
+	return out.Choices[0].Message.Content, time.Since(start), nil
+}
+
+// ChatStream is Chat with "stream": true; onToken is called for every SSE delta.
+// A long reply may take as long as ctx allows, but the upstream must send a line at
+// least every streamIdle or the request is cancelled with ErrStreamStalled.
+func (c *Client) ChatStream(ctx context.Context, model string, messages []ChatMessage, opts types.GenerateOptions, onToken func(string) error) (string, time.Duration, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var stalled atomic.Bool
+	idle := time.AfterFunc(streamIdle, func() {
+		stalled.Store(true)
+		cancel()
+	})
+	defer idle.Stop()
+
+	req, err := c.newRequest(ctx, http.MethodPost, "/chat/completions", chatPayload(model, messages, opts, true))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	start := time.Now()
+	res, err := c.send(c.stream, req, "chat")
+	if err != nil {
+		if stalled.Load() {
+			err = ErrStreamStalled
+		}
+		return "", 0, err
+	}
+	defer res.Body.Close()
+	text, err := readStream(res.Body, func() { idle.Reset(streamIdle) }, onToken)
+	if err != nil && stalled.Load() {
+		err = ErrStreamStalled
+	}
+	return text, time.Since(start), err
+}
+
+// streamChunk is one "data:" event of a streamed chat completion.
+type streamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// readStream decodes SSE "data:" lines until [DONE], forwarding text to onToken and
+// calling alive for every line read. The text collected so far is returned alongside
+// any error.
+func readStream(r io.Reader, alive func(), onToken func(string) error) (string, error) {
+	var sb strings.Builder
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	for sc.Scan() {
+		alive()
+		data, ok := strings.CutPrefix(sc.Text(), "data:")
+		if !ok {
+			continue // blank separators, comments, event names
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			return sb.String(), nil
+		}
+		var chunk streamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return sb.String(), err
+		}
+		if chunk.Error != nil {
+			return sb.String(), fmt.Errorf("openai chat: %s", chunk.Error.Message)
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+		text := chunk.Choices[0].Delta.Content
+		sb.WriteString(text)
+		if onToken != nil {
+			if err := onToken(text); err != nil {
+				return sb.String(), err
+			}
+		}
+	}
+	return sb.String(), sc.Err()
+}
@@ -0,0 +1,177 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/varsilias/zero-downtime/pkg/types"
+)
+
+// fakeServer answers /models and /chat/completions like an OpenAI-compatible server,
+// after checking the credentials and custom header every request must carry.
+func fakeServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer sk-test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":{"message":"bad key `+got+`"}}`)
+			return
+		}
+		if got := r.Header.Get("X-Route"); got != "blue" {
+			t.Errorf("X-Route = %q, want blue", got)
+		}
+		switch r.URL.Path {
+		case "/v1/models":
+			fmt.Fprint(w, `{"object":"list","data":[{"id":"llama3","object":"model","created":1700000000,"owned_by":"vllm"}]}`)
+		case "/v1/chat/completions":
+			var req struct {
+				Model    string        `json:"model"`
+				Messages []ChatMessage `json:"messages"`
+				Stream   bool          `json:"stream"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("decode request: %v", err)
+			}
+			switch {
+			case req.Model == "missing":
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"error":{"message":"model missing does not exist"}}`)
+			case req.Model == "broken":
+				w.WriteHeader(http.StatusBadGateway)
+				fmt.Fprint(w, "upstream down")
+			case req.Stream && (req.Model == "slow" || req.Model == "stalled"):
+				// a token every 40ms; "stalled" goes quiet after the second
+				for i := 0; i < 6; i++ {
+					fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":\"%d\"}}]}\n\n", i)
+					w.(http.Flusher).Flush()
+					wait := 40 * time.Millisecond
+					if req.Model == "stalled" && i == 1 {
+						wait = time.Second
+					}
+					select {
+					case <-r.Context().Done():
+						return
+					case <-time.After(wait):
+					}
+				}
+				fmt.Fprint(w, "data: [DONE]\n\n")
+			case req.Stream:
+				w.Header().Set("Content-Type", "text/event-stream")
+				for _, tok := range []string{"Hel", "lo"} {
+					fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", tok)
+				}
+				fmt.Fprint(w, ": keep-alive comment\n\ndata: [DONE]\n\ndata: {\"choices\":[{\"delta\":{\"content\":\"after done\"}}]}\n\n")
+			default:
+				last := req.Messages[len(req.Messages)-1].Content
+				fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":"echo: %s (%d turns)"}}]}`, last, len(req.Messages))
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestClient(srv *httptest.Server, key string) *Client {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewClient(srv.URL+"/v1/", key, map[string]string{"X-Route": "blue"}, log)
+}
+
+func TestModels(t *testing.T) {
+	c := newTestClient(fakeServer(t), "sk-test")
+	models, err := c.Models(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 1 || models[0].ID != "llama3" || models[0].Created != 1700000000 {
+		t.Fatalf("Models = %+v", models)
+	}
+	if err := c.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping = %v", err)
+	}
+}
+
+func TestChat(t *testing.T) {
+	c := newTestClient(fakeServer(t), "sk-test")
+	msgs := []ChatMessage{{Role: "system", Content: "be brief"}, {Role: "user", Content: "hi"}}
+	text, _, err := c.Chat(context.Background(), "llama3", msgs, types.GenerateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "echo: hi (2 turns)" {
+		t.Fatalf("Chat = %q", text)
+	}
+}
+
+func TestChatStream(t *testing.T) {
+	c := newTestClient(fakeServer(t), "sk-test")
+	var tokens []string
+	text, _, err := c.ChatStream(context.Background(), "llama3", []ChatMessage{{Role: "user", Content: "hi"}}, types.GenerateOptions{},
+		func(tok string) error { tokens = append(tokens, tok); return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	// nothing after [DONE] is read
+	if text != "Hello" || strings.Join(tokens, "|") != "Hel|lo" {
+		t.Fatalf("ChatStream = %q, tokens %q", text, tokens)
+	}
+}
+
+// TestChatStreamIdle checks that a stream runs for as long as lines keep coming, well
+// past the idle limit in total, and gives up once they stop.
+func TestChatStreamIdle(t *testing.T) {
+	defer func(d time.Duration) { streamIdle = d }(streamIdle)
+	streamIdle = 100 * time.Millisecond
+	c := newTestClient(fakeServer(t), "sk-test")
+	msgs := []ChatMessage{{Role: "user", Content: "hi"}}
+
+	text, took, err := c.ChatStream(context.Background(), "slow", msgs, types.GenerateOptions{}, nil)
+	if err != nil || text != "012345" {
+		t.Fatalf("slow ChatStream = %q, %v", text, err)
+	}
+	if took < 2*streamIdle {
+		t.Fatalf("stream took %v, want it to outlive the idle limit", took)
+	}
+
+	text, _, err = c.ChatStream(context.Background(), "stalled", msgs, types.GenerateOptions{}, nil)
+	if !errors.Is(err, ErrStreamStalled) || text != "01" {
+		t.Fatalf("stalled ChatStream = %q, %v; want \"01\", ErrStreamStalled", text, err)
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	srv := fakeServer(t)
+	msgs := []ChatMessage{{Role: "user", Content: "hi"}}
+	for _, tc := range []struct {
+		name string
+		call func() error
+		want string
+	}{
+		{"unauthorized", func() error { _, err := newTestClient(srv, "wrong").Models(context.Background()); return err },
+			"openai models: 401 Unauthorized: bad key Bearer wrong"},
+		{"error message", func() error {
+			_, _, err := newTestClient(srv, "sk-test").Chat(context.Background(), "missing", msgs, types.GenerateOptions{})
+			return err
+		}, "openai chat: 404 Not Found: model missing does not exist"},
+		{"plain body", func() error {
+			_, _, err := newTestClient(srv, "sk-test").ChatStream(context.Background(), "broken", msgs, types.GenerateOptions{}, nil)
+			return err
+		}, "openai chat: 502 Bad Gateway: upstream down"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.call(); err == nil || err.Error() != tc.want {
+				t.Fatalf("err = %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package routing
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Route sends the models matching Match to the backend named Backend. Match is an
+// exact model name, or a prefix ending in "*" (e.g. "gemma3:*", "gpt-*").
+type Route struct {
+	Match   string `json:"match"`
+	Backend string `json:"backend"`
+}
+
+func (r Route) prefix() (string, bool) {
+	return strings.CutSuffix(r.Match, "*")
+}
+
+// Table resolves a model name to a backend: an exact match wins, then the longest
+// matching prefix, then the default backend (if any).
+type Table struct {
+	exact    map[string]string
+	prefixes []Route // longest prefix first
+	def      string
+}
+
+// NewTable validates routes against the known backends. def may be empty, in which
+// case models without a route cannot be served.
+func NewTable(routes []Route, def string, backends []string) (*Table, error) {
+	known := map[string]bool{}
+	for _, b := range backends {
+		known[b] = true
+	}
+	if def != "" && !known[def] {
+		return nil, fmt.Errorf("default backend %q is not defined", def)
+	}
+	t := &Table{exact: map[string]string{}, def: def}
+	for i, r := range routes {
+		if r.Match == "" {
+			return nil, fmt.Errorf("route %d: match is required", i)
+		}
+		if !known[r.Backend] {
+			return nil, fmt.Errorf("route %d (%s): backend %q is not defined", i, r.Match, r.Backend)
+		}
+		if _, ok := r.prefix(); ok {
+			t.prefixes = append(t.prefixes, r)
+			continue
+		}
+		if prev, dup := t.exact[r.Match]; dup && prev != r.Backend {
+			return nil, fmt.Errorf("route %d: %q is already routed to %q", i, r.Match, prev)
+		}
+		t.exact[r.Match] = r.Backend
+	}
+	sort.SliceStable(t.prefixes, func(i, j int) bool { return len(t.prefixes[i].Match) > len(t.prefixes[j].Match) })
+	return t, nil
+}
+
+// Resolve returns the backend for model, or false when no route (and no default) applies.
+func (t *Table) Resolve(model string) (string, bool) {
+	if b, ok := t.exact[model]; ok {
+		return b, true
+	}
+	for _, r := range t.prefixes {
+		if p, _ := r.prefix(); strings.HasPrefix(model, p) {
+			return r.Backend, true
+		}
+	}
+	return t.def, t.def != ""
+}
@@ -0,0 +1,134 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/varsilias/zero-downtime/pkg/types"
+)
+
+// Format is a conversation export format.
+type Format string
+
+const (
+	FormatMarkdown Format = "md"
+	FormatJSON     Format = "json"
+	// FormatJSONL is one {"messages":[...]} object per line, the shape fine-tuning
+	// tools expect.
+	FormatJSONL Format = "jsonl"
+)
+
+// ParseFormat accepts md, json or jsonl; empty means md.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case "":
+		return FormatMarkdown, nil
+	case FormatMarkdown, FormatJSON, FormatJSONL:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown export format %q (want md, json or jsonl)", s)
+	}
+}
+
+func (f Format) ContentType() string {
+	switch f {
+	case FormatJSON:
+		return "application/json; charset=utf-8"
+	case FormatJSONL:
+		return "application/jsonl; charset=utf-8"
+	default:
+		return "text/markdown; charset=utf-8"
+	}
+}
+
+// Conversation is one exported branch of a session.
+type Conversation struct {
+	ID         string          `json:"id"`
+	Title      string          `json:"title,omitempty"`
+	System     string          `json:"system,omitempty"`
+	ExportedAt time.Time       `json:"exported_at"`
+	Messages   []types.Message `json:"messages"` // root first
+}
+
+// Export writes conv in format f.
+func Export(w io.Writer, f Format, conv Conversation) error {
+	switch f {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(conv)
+	case FormatJSONL:
+		return exportJSONL(w, conv)
+	default:
+		return exportMarkdown(w, conv)
+	}
+}
+
+// turn is a message in the fine-tuning shape: role and content only.
+type turn struct {
+	Role    types.Role `json:"role"`
+	Content string     `json:"content"`
+}
+
+func exportJSONL(w io.Writer, conv Conversation) error {
+	line := struct {
+		Messages []turn `json:"messages"`
+	}{Messages: make([]turn, 0, len(conv.Messages)+1)}
+	if conv.System != "" {
+		line.Messages = append(line.Messages, turn{Role: types.RoleSystem, Content: conv.System})
+	}
+	for _, m := range conv.Messages {
+		line.Messages = append(line.Messages, turn{Role: m.Role, Content: m.Content})
+	}
+	return json.NewEncoder(w).Encode(line) // Encode ends the line
+}
+
+func exportMarkdown(w io.Writer, conv Conversation) error {
+	var b strings.Builder
+	title := conv.Title
+	if title == "" {
+		title = "Chat " + conv.ID
+	}
+	fmt.Fprintf(&b, "# %s\n\n", title)
+	fmt.Fprintf(&b, "_Session `%s`, exported %s_\n\n", conv.ID, conv.ExportedAt.UTC().Format(time.RFC3339))
+	if conv.System != "" {
+		fmt.Fprintf(&b, "**System prompt**\n\n%s\n\n", quote(conv.System))
+	}
+	for _, m := range conv.Messages {
+		b.WriteString("---\n\n### ")
+		b.WriteString(roleName(m.Role))
+		meta := []string{m.Timestamp.UTC().Format(time.RFC3339)}
+		if m.Model != "" {
+			meta = append(meta, m.Model)
+		}
+		if m.LatencyMS > 0 {
+			meta = append(meta, (time.Duration(m.LatencyMS) * time.Millisecond).String())
+		}
+		if m.Stopped {
+			meta = append(meta, "stopped")
+		}
+		fmt.Fprintf(&b, "\n\n_%s_\n\n%s\n\n", strings.Join(meta, " · "), strings.TrimSpace(m.Content))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func roleName(r types.Role) string {
+	switch r {
+	case types.RoleUser:
+		return "User"
+	case types.RoleAssistant:
+		return "Assistant"
+	case types.RoleSystem:
+		return "System"
+	}
+	return string(r)
+}
+
+// quote renders s as a Markdown blockquote.
+func quote(s string) string {
+	return "> " + strings.ReplaceAll(strings.TrimSpace(s), "\n", "\n> ")
+}
@@ -0,0 +1,184 @@
+package session
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/varsilias/zero-downtime/pkg/types"
+)
+
+// ErrInvalidConversation wraps every reason ParseConversation rejects a file.
+var ErrInvalidConversation = errors.New("invalid conversation")
+
+// ErrTooManyMessages is returned by Import when a conversation has more messages than
+// the store keeps per session.
+var ErrTooManyMessages = errors.New("conversation exceeds the per-session message limit")
+
+// messageLimiter is implemented by stores that cap the messages of one session.
+type messageLimiter interface {
+	MessageLimit() int
+}
+
+const (
+	// MaxImportBytes bounds an uploaded conversation file.
+	MaxImportBytes = 8 << 20
+	// MaxImportMessages bounds the messages of one imported conversation.
+	MaxImportMessages = 2000
+	// MaxImportContent bounds a single imported message, in bytes.
+	MaxImportContent = 256 << 10
+)
+
+// importMessage is a message in any accepted format: our own export (role, content,
+// timestamp, model, latency_ms, stopped) or OpenAI's (content may be an array of parts).
+type importMessage struct {
+	Role      string          `json:"role"`
+	Content   json.RawMessage `json:"content"`
+	Timestamp time.Time       `json:"timestamp"`
+	Model     string          `json:"model"`
+	LatencyMS int64           `json:"latency_ms"`
+	Stopped   bool            `json:"stopped"`
+}
+
+// ParseConversation reads one conversation from data, which may be
+//   - our JSON export ({"title","system","messages":[...]}),
+//   - a JSONL export or OpenAI fine-tuning line ({"messages":[...]}, one line only),
+//   - or a bare OpenAI messages array ([{"role","content"}, ...]).
+//
+// Leading system messages become the system prompt. The exported session ID is not kept.
+func ParseConversation(data []byte) (Conversation, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return Conversation{}, invalid("empty file")
+	}
+	var doc struct {
+		Title    string          `json:"title"`
+		System   string          `json:"system"`
+		Messages []importMessage `json:"messages"`
+	}
+	if data[0] == '[' {
+		if err := json.Unmarshal(data, &doc.Messages); err != nil {
+			return Conversation{}, invalid("messages array: %v", err)
+		}
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		if err := dec.Decode(&doc); err != nil {
+			return Conversation{}, invalid("%v", err)
+		}
+		if dec.More() {
+			return Conversation{}, invalid("one conversation per file (found more than one JSONL line)")
+		}
+	}
+
+	conv := Conversation{Title: strings.TrimSpace(doc.Title), System: strings.TrimSpace(doc.System)}
+	if len(conv.Title) > MaxTitle {
+		return Conversation{}, invalid("title longer than %d bytes", MaxTitle)
+	}
+	if len(doc.Messages) == 0 {
+		return Conversation{}, invalid("no messages")
+	}
+	if len(doc.Messages) > MaxImportMessages {
+		return Conversation{}, invalid("more than %d messages", MaxImportMessages)
+	}
+	for i, im := range doc.Messages {
+		content, err := importContent(im.Content)
+		if err != nil {
+			return Conversation{}, invalid("message %d: %v", i, err)
+		}
+		if len(content) > MaxImportContent {
+			return Conversation{}, invalid("message %d: longer than %d bytes", i, MaxImportContent)
+		}
+		switch role := types.Role(strings.ToLower(im.Role)); role {
+		case types.RoleSystem, "developer":
+			if len(conv.Messages) > 0 {
+				return Conversation{}, invalid("message %d: system messages are only supported before the conversation", i)
+			}
+			conv.System = strings.TrimSpace(strings.Join([]string{conv.System, content}, "\n\n"))
+		case types.RoleUser, types.RoleAssistant:
+			conv.Messages = append(conv.Messages, types.Message{
+				Role: role, Content: content, Timestamp: im.Timestamp,
+				Model: im.Model, LatencyMS: im.LatencyMS, Stopped: im.Stopped,
+			})
+		default:
+			return Conversation{}, invalid("message %d: unsupported role %q", i, im.Role)
+		}
+	}
+	if len(conv.Messages) == 0 {
+		return Conversation{}, invalid("no user or assistant messages")
+	}
+	return conv, nil
+}
+
+// importContent accepts a string or an OpenAI array of content parts, of which only
+// text parts are kept.
+func importContent(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, nil
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return "", errors.New("content must be a string or an array of parts")
+	}
+	var texts []string
+	for _, p := range parts {
+		if p.Type == "text" || p.Type == "input_text" || p.Type == "output_text" {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n"), nil
+}
+
+func invalid(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalidConversation, fmt.Sprintf(format, args...))
+}
+
+// Import stores conv as the new session sessionID, as a single linear branch.
+// Messages get fresh IDs; missing timestamps are set to now. A conversation longer than
+// the store's per-session cap is rejected with ErrTooManyMessages, and a failed import
+// deletes the half-written session.
+func Import(s Store, sessionID string, conv Conversation) (err error) {
+	if l, ok := s.(messageLimiter); ok {
+		if limit := l.MessageLimit(); limit > 0 && len(conv.Messages) > limit {
+			return fmt.Errorf("%w: %d messages, limit %d", ErrTooManyMessages, len(conv.Messages), limit)
+		}
+	}
+	if err := s.Create(sessionID); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = s.Delete(sessionID)
+		}
+	}()
+	if conv.Title != "" {
+		if err := s.Rename(sessionID, conv.Title); err != nil {
+			return err
+		}
+	}
+	if conv.System != "" {
+		if err := s.SetSystemPrompt(sessionID, conv.System); err != nil {
+			return err
+		}
+	}
+	now := time.Now()
+	for _, m := range conv.Messages {
+		m.ID, m.ParentID, m.Context = "", "", nil
+		if m.Timestamp.IsZero() {
+			m.Timestamp = now
+		}
+		if err := s.Append(sessionID, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
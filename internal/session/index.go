@@ -0,0 +1,85 @@
+package session
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/varsilias/zero-downtime/pkg/types"
+)
+
+type msgRef struct{ session, message string }
+
+// invertedIndex maps each word to the messages containing it, with their timestamps so
+// lookups can rank without touching message content. Not safe for concurrent use.
+type invertedIndex map[string]map[msgRef]time.Time
+
+func (ix invertedIndex) add(sessionID string, m types.Message) {
+	ref := msgRef{sessionID, m.ID}
+	for _, t := range tokens(m.Content) {
+		postings, ok := ix[t.text]
+		if !ok {
+			postings = make(map[msgRef]time.Time)
+			ix[t.text] = postings
+		}
+		postings[ref] = m.Timestamp
+	}
+}
+
+func (ix invertedIndex) remove(sessionID string, m types.Message) {
+	ref := msgRef{sessionID, m.ID}
+	for _, t := range tokens(m.Content) {
+		if postings, ok := ix[t.text]; ok {
+			delete(postings, ref)
+			if len(postings) == 0 {
+				delete(ix, t.text)
+			}
+		}
+	}
+}
+
+// lookup returns the messages containing every term (the last one as a prefix),
+// newest first, at most limit.
+func (ix invertedIndex) lookup(terms []string, limit int) []msgRef {
+	var found map[msgRef]time.Time
+	for i, term := range terms {
+		matched := ix[term]
+		if i == len(terms)-1 {
+			matched = ix.prefix(term)
+		}
+		if found == nil {
+			found = matched
+			continue
+		}
+		next := make(map[msgRef]time.Time)
+		for ref, ts := range found {
+			if _, ok := matched[ref]; ok {
+				next[ref] = ts
+			}
+		}
+		found = next
+	}
+
+	refs := make([]msgRef, 0, len(found))
+	for ref := range found {
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i, j int) bool { return found[refs[i]].After(found[refs[j]]) })
+	if len(refs) > limit {
+		refs = refs[:limit]
+	}
+	return refs
+}
+
+// prefix merges the postings of every word starting with p.
+func (ix invertedIndex) prefix(p string) map[msgRef]time.Time {
+	out := make(map[msgRef]time.Time)
+	for w, postings := range ix {
+		if strings.HasPrefix(w, p) {
+			for ref, ts := range postings {
+				out[ref] = ts
+			}
+		}
+	}
+	return out
+}
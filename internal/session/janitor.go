@@ -0,0 +1,78 @@
+package session
+
+import (
+	"errors"
+	"expvar"
+	"time"
+)
+
+// MemoryLimits bound a MemoryStore so it fits a fixed memory budget; zero disables a limit.
+//
+// Pinned sessions are never evicted, neither when idle nor for capacity. To keep the
+// session cap a hard bound, at most MaxSessions-1 sessions can be pinned at once.
+type MemoryLimits struct {
+	MaxSessions int           // enforced on insert: the least recently used unpinned session makes room
+	MaxMessages int           // per session; the oldest messages beyond this are dropped
+	IdleTTL     time.Duration // unpinned sessions unused for this long are evicted
+	Sweep       time.Duration // janitor interval; defaults to a minute
+}
+
+// ErrPinLimit is returned by MemoryStore.Pin when pinning would leave no session evictable.
+var ErrPinLimit = errors.New("too many pinned sessions")
+
+// Evictions counts what a MemoryStore freed, by reason: "idle" and "capacity" count
+// sessions, "messages" counts trimmed messages. Published on /debug/vars.
+var Evictions = expvar.NewMap("session_evictions")
+
+func (s *MemoryStore) janitor(every time.Duration) {
+	defer close(s.done)
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-t.C:
+			s.evict(now)
+		}
+	}
+}
+
+// evict drops unpinned sessions unused since IdleTTL before now.
+func (s *MemoryStore) evict(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cutoff := now.Add(-s.limits.IdleTTL)
+	for e := s.lru.Back(); e != nil; {
+		ms := e.Value.(*memSession)
+		if ms.used.After(cutoff) {
+			break // the rest were used more recently
+		}
+		e = e.Prev()
+		if !ms.pinned {
+			s.drop(ms)
+			Evictions.Add("idle", 1)
+		}
+	}
+}
+
+// makeRoom drops the least recently used unpinned sessions, other than keep, until the
+// store is back within MaxSessions. Must be called with s.mu held.
+func (s *MemoryStore) makeRoom(keep *memSession) {
+	e := s.lru.Back()
+	for s.limits.MaxSessions > 0 && s.lru.Len() > s.limits.MaxSessions && e != nil {
+		ms := e.Value.(*memSession)
+		e = e.Prev()
+		if ms != keep && !ms.pinned {
+			s.drop(ms)
+			Evictions.Add("capacity", 1)
+		}
+	}
+}
+
+// Close stops the janitor and waits for it to exit. Sessions stay readable.
+func (s *MemoryStore) Close() error {
+	s.closeOnce.Do(func() { close(s.stop) })
+	<-s.done
+	return nil
+}
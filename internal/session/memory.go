@@ -1,29 +1,114 @@
 package session
 
 import (
+	"container/list"
 	"errors"
 	"github.com/varsilias/zero-downtime/pkg/types"
-	"strings"
 	"sync"
 	"time"
 )
 
-type Store interface {
-	Append(sessionID string, m types.Message) error
-	Get(sessionID string) ([]types.Message, error)
+type MemoryStore struct {
+	mu       sync.RWMutex
+	sessions map[string]*memSession
+	lru      *list.List // of *memSession, most recently used first
+	limits   MemoryLimits
+	index    invertedIndex
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
-type MemoryStore struct {
-	mu      sync.RWMutex
-	data    map[string][]types.Message
-	updated map[string]time.Time
+type memSession struct {
+	id      string
+	elem    *list.Element   // in MemoryStore.lru
+	used    time.Time       // last read or write, for idle eviction
+	msgs    []types.Message // every message of the tree, in insertion order
+	head    string          // leaf of the active branch
+	system  string
+	title   string
+	pinned  bool
+	trimmed int // messages dropped by trim, reported in the summary
+	created time.Time
+	updated time.Time
+}
+
+// NewMemoryStore returns an empty store. The session and message caps are enforced on
+// insert; when limits set an idle TTL, a janitor goroutine enforces it until Close.
+func NewMemoryStore(limits MemoryLimits) *MemoryStore {
+	s := &MemoryStore{
+		sessions: make(map[string]*memSession),
+		lru:      list.New(),
+		limits:   limits,
+		index:    make(invertedIndex),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if limits.IdleTTL > 0 {
+		every := limits.Sweep
+		if every <= 0 {
+			every = time.Minute
+		}
+		go s.janitor(every)
+	} else {
+		close(s.done)
+	}
+	return s
 }
 
-func NewMemoryStore() *MemoryStore {
-	return &MemoryStore{
-		data:    make(map[string][]types.Message),
-		updated: make(map[string]time.Time),
+// session returns the session, creating it if needed (evicting another one beyond
+// MaxSessions), and marks it used. Must be called with s.mu held.
+func (s *MemoryStore) session(sessionID string) *memSession {
+	ms, ok := s.sessions[sessionID]
+	if !ok {
+		now := time.Now()
+		ms = &memSession{id: sessionID, created: now, updated: now}
+		ms.elem = s.lru.PushFront(ms)
+		s.sessions[sessionID] = ms
+		s.makeRoom(ms)
 	}
+	s.use(ms)
+	return ms
+}
+
+// use moves ms to the front of the LRU list. Must be called with s.mu held.
+func (s *MemoryStore) use(ms *memSession) {
+	ms.used = time.Now()
+	s.lru.MoveToFront(ms.elem)
+}
+
+// drop forgets a session. Must be called with s.mu held.
+func (s *MemoryStore) drop(ms *memSession) {
+	for _, m := range ms.msgs {
+		s.index.remove(ms.id, m)
+	}
+	s.lru.Remove(ms.elem)
+	delete(s.sessions, ms.id)
+}
+
+// trim drops the oldest messages beyond MaxMessages. The active leaf is the newest
+// message right after an insert, so the active branch only loses its oldest turns.
+// Messages whose parent was dropped become roots, and the count shows up in the
+// session's summary and in Evictions.
+func (s *MemoryStore) trim(ms *memSession) {
+	n := len(ms.msgs) - s.limits.MaxMessages
+	if s.limits.MaxMessages <= 0 || n <= 0 {
+		return
+	}
+	dropped := make(map[string]bool, n)
+	for _, m := range ms.msgs[:n] {
+		s.index.remove(ms.id, m)
+		dropped[m.ID] = true
+	}
+	ms.msgs = append([]types.Message(nil), ms.msgs[n:]...)
+	for i := range ms.msgs {
+		if dropped[ms.msgs[i].ParentID] {
+			ms.msgs[i].ParentID = ""
+		}
+	}
+	ms.trimmed += n
+	Evictions.Add("messages", int64(n))
 }
 
 func (s *MemoryStore) Append(sessionID string, m types.Message) error {
@@ -32,70 +117,203 @@ func (s *MemoryStore) Append(sessionID string, m types.Message) error {
 	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.data[sessionID] = append(s.data[sessionID], m)
+	ms := s.session(sessionID)
+	s.index.add(sessionID, ms.insert(ms.head, m))
+	s.trim(ms)
 	return nil
 }
 
+func (s *MemoryStore) Fork(sessionID, parentID string, m types.Message) error {
+	if sessionID == "" {
+		return errors.New("empty session id")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if parentID != "" {
+		if _, ok := Find(s.sessions[sessionID].messages(), parentID); !ok {
+			return ErrNotFound
+		}
+	}
+	ms := s.session(sessionID)
+	s.index.add(sessionID, ms.insert(parentID, m))
+	s.trim(ms)
+	return nil
+}
+
+// messages is nil-safe so lookups on unknown sessions need no special case.
+func (ms *memSession) messages() []types.Message {
+	if ms == nil {
+		return nil
+	}
+	return ms.msgs
+}
+
+// insert returns the stored message, with its ID and parent set.
+func (ms *memSession) insert(parentID string, m types.Message) types.Message {
+	if m.ID == "" {
+		m.ID = NewMessageID()
+	}
+	m.ParentID = parentID
+	ms.msgs = append(ms.msgs, m)
+	ms.head = m.ID
+	ms.updated = time.Now()
+	return m
+}
+
 func (s *MemoryStore) Get(sessionID string) ([]types.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ms, ok := s.sessions[sessionID]
+	if !ok {
+		return nil, nil
+	}
+	s.use(ms)
+	return Branch(ms.msgs, ms.head), nil
+}
+
+func (s *MemoryStore) Tree(sessionID string) ([]types.Message, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	msgs := s.data[sessionID]
+	msgs := s.sessions[sessionID].messages()
 	out := make([]types.Message, len(msgs))
 	copy(out, msgs)
 	return out, nil
 }
 
-// List returns lightweight session summaries (best effort).
-type Summary struct {
-	ID      string
-	Title   string
-	Updated time.Time
+func (s *MemoryStore) Checkout(sessionID, messageID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ms := s.sessions[sessionID]
+	if _, ok := Find(ms.messages(), messageID); !ok {
+		return ErrNotFound
+	}
+	ms.head = messageID
+	ms.updated = time.Now()
+	s.use(ms)
+	return nil
+}
+
+func (s *MemoryStore) SetSystemPrompt(sessionID, prompt string) error {
+	if sessionID == "" {
+		return errors.New("empty session id")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ms := s.session(sessionID)
+	ms.system = prompt
+	ms.updated = time.Now()
+	return nil
+}
+
+func (s *MemoryStore) SystemPrompt(sessionID string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if ms, ok := s.sessions[sessionID]; ok {
+		return ms.system, nil
+	}
+	return "", nil
+}
+
+func (s *MemoryStore) List() ([]Summary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Summary, 0, len(s.sessions))
+	for id, ms := range s.sessions {
+		out = append(out, ms.summary(id))
+	}
+	sortSummaries(out)
+	return out, nil
+}
+
+func (ms *memSession) summary(id string) Summary {
+	title, custom := summaryTitle(ms.title, ms.msgs)
+	return Summary{ID: id, Title: title, Custom: custom, Pinned: ms.pinned, Messages: len(ms.msgs), Trimmed: ms.trimmed, Created: ms.created, Updated: ms.updated}
 }
 
-func (s *MemoryStore) List() []Summary {
+func (s *MemoryStore) Create(sessionID string) error {
+	if sessionID == "" {
+		return errors.New("empty session id")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.session(sessionID)
+	return nil
+}
+
+func (s *MemoryStore) Meta(sessionID string) (Summary, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	out := make([]Summary, 0, len(s.data))
-	for id, msgs := range s.data {
-		out = append(out, Summary{ID: id, Title: titleFrom(msgs), Updated: s.updated[id]})
+	ms, ok := s.sessions[sessionID]
+	if !ok {
+		return Summary{}, ErrSessionNotFound
 	}
-	return out
+	return ms.summary(sessionID), nil
 }
 
-// Touch ensures a session exists in the list.
-func (s *MemoryStore) Touch(sessionID string) {
+func (s *MemoryStore) Rename(sessionID, title string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if _, ok := s.data[sessionID]; !ok {
-		s.data[sessionID] = nil
+	ms, ok := s.sessions[sessionID]
+	if !ok {
+		return ErrSessionNotFound
 	}
-	s.updated[sessionID] = time.Now()
+	ms.title = title
+	return nil
 }
 
-func titleFrom(msgs []types.Message) string {
-	for _, m := range msgs {
-		if m.Role == types.RoleUser {
-			return clip(words(m.Content), 8)
-		}
+func (s *MemoryStore) Pin(sessionID string, pinned bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ms, ok := s.sessions[sessionID]
+	if !ok {
+		return ErrSessionNotFound
 	}
-	return ""
+	if pinned && !ms.pinned && s.limits.MaxSessions > 0 && s.pinnedCount()+1 >= s.limits.MaxSessions {
+		return ErrPinLimit
+	}
+	ms.pinned = pinned
+	return nil
 }
 
-func words(s string) string {
-	s = strings.TrimSpace(s)
-	if s == "" {
-		return s
+// MessageLimit is the per-session cap (MemoryLimits.MaxMessages); 0 means none.
+func (s *MemoryStore) MessageLimit() int { return s.limits.MaxMessages }
+
+// pinnedCount must be called with s.mu held.
+func (s *MemoryStore) pinnedCount() int {
+	n := 0
+	for _, ms := range s.sessions {
+		if ms.pinned {
+			n++
+		}
 	}
-	parts := strings.Fields(s)
-	if len(parts) <= 12 {
-		return s
+	return n
+}
+
+func (s *MemoryStore) Delete(sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ms, ok := s.sessions[sessionID]
+	if !ok {
+		return ErrSessionNotFound
 	}
-	return strings.Join(parts[:12], " ")
+	s.drop(ms)
+	return nil
 }
 
-func clip(s string, n int) string {
-	if len(s) <= n*2 {
-		return s
+func (s *MemoryStore) Search(query string, limit int) ([]Hit, error) {
+	terms := ParseQuery(query)
+	if len(terms) == 0 {
+		return []Hit{}, nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	refs := s.index.lookup(terms, searchLimit(limit))
+	hits := make([]Hit, 0, len(refs))
+	for _, ref := range refs {
+		m, ok := Find(s.sessions[ref.session].messages(), ref.message)
+		if ok {
+			hits = append(hits, newHit(ref.session, m, terms))
+		}
 	}
-	return s[:n*2] + "…"
+	return hits, nil
 }
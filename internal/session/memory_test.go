@@ -0,0 +1,67 @@
+package session
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/varsilias/zero-downtime/pkg/types"
+)
+
+func TestMemoryStoreCapacity(t *testing.T) {
+	s := NewMemoryStore(MemoryLimits{MaxSessions: 3})
+	defer s.Close()
+	for _, id := range []string{"a", "b", "c"} {
+		mustAppend(t, s, id, types.RoleUser, "hi "+id)
+	}
+	for _, id := range []string{"a", "b"} {
+		if err := s.Pin(id, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Pin("c", true); !errors.Is(err, ErrPinLimit) {
+		t.Fatalf("third pin = %v, want ErrPinLimit", err)
+	}
+
+	// the cap holds on insert, and the oldest pinned session "a" survives it
+	mustAppend(t, s, "d", types.RoleUser, "hi d")
+	if _, err := s.Meta("c"); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("c survived: %v", err)
+	}
+	mustAppend(t, s, "e", types.RoleUser, "hi e")
+	list, _ := s.List()
+	if len(list) != 3 || !list[0].Pinned || !list[1].Pinned || list[2].ID != "e" {
+		t.Fatalf("List = %+v, want a, b and e", list)
+	}
+}
+
+func TestMemoryStoreTrim(t *testing.T) {
+	s := NewMemoryStore(MemoryLimits{MaxMessages: 3})
+	defer s.Close()
+	for _, c := range []string{"q1", "a1", "q2", "a2"} {
+		mustAppend(t, s, "s1", types.RoleUser, c)
+	}
+	got := mustGet(t, s, "s1")
+	if !equal(contents(got), []string{"a1", "q2", "a2"}) || got[0].ParentID != "" {
+		t.Fatalf("Get = %v, root parent %q", contents(got), got[0].ParentID)
+	}
+	if sum, _ := s.Meta("s1"); sum.Messages != 3 || sum.Trimmed != 1 {
+		t.Fatalf("Meta = %+v, want 3 messages and 1 trimmed", sum)
+	}
+}
+
+func TestImportMessageLimit(t *testing.T) {
+	s := NewMemoryStore(MemoryLimits{MaxMessages: 2})
+	defer s.Close()
+	conv := Conversation{Messages: []types.Message{
+		{Role: types.RoleUser, Content: "q"}, {Role: types.RoleAssistant, Content: "a"}, {Role: types.RoleUser, Content: "q2"},
+	}}
+	if err := Import(s, "imp", conv); !errors.Is(err, ErrTooManyMessages) {
+		t.Fatalf("Import = %v, want ErrTooManyMessages", err)
+	}
+	if _, err := s.Meta("imp"); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("rejected import left a session: %v", err)
+	}
+	if err := Import(s, "imp", Conversation{Messages: conv.Messages[:2]}); err != nil {
+		t.Fatal(err)
+	}
+}
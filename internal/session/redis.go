@@ -0,0 +1,386 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/varsilias/zero-downtime/pkg/types"
+)
+
+// RedisStore is a Store on a Redis-protocol server, so every replica sees the same
+// sessions. Per session it keeps:
+//
+//	{prefix}s:{id}       hash: head, system, title, pinned, created, updated
+//	{prefix}s:{id}:msgs  list: every message as JSON, in insertion order
+//	{prefix}s:{id}:ids   set: message IDs, for parent/checkout validation
+//	{prefix}sessions     sorted set: session IDs scored by last update (unix ms)
+type RedisStore struct {
+	rdb    redis.UniversalClient
+	prefix string
+}
+
+// maxTxRetries bounds optimistic-lock retries when two replicas write the same session.
+const maxTxRetries = 8
+
+// NewRedisStore wraps rdb; prefix namespaces the keys (e.g. "zd:").
+func NewRedisStore(rdb redis.UniversalClient, prefix string) *RedisStore {
+	return &RedisStore{rdb: rdb, prefix: prefix}
+}
+
+// Close closes the underlying client.
+func (s *RedisStore) Close() error {
+	return s.rdb.Close()
+}
+
+func (s *RedisStore) metaKey(sessionID string) string { return s.prefix + "s:" + sessionID }
+func (s *RedisStore) msgsKey(sessionID string) string { return s.prefix + "s:" + sessionID + ":msgs" }
+func (s *RedisStore) idsKey(sessionID string) string  { return s.prefix + "s:" + sessionID + ":ids" }
+func (s *RedisStore) indexKey() string                { return s.prefix + "sessions" }
+
+// touch queues the updated-time bookkeeping shared by every write.
+func (s *RedisStore) touch(ctx context.Context, p redis.Pipeliner, sessionID string, now time.Time) {
+	p.HSetNX(ctx, s.metaKey(sessionID), "created", now.UnixNano())
+	p.HSet(ctx, s.metaKey(sessionID), "updated", now.UnixNano())
+	p.ZAdd(ctx, s.indexKey(), redis.Z{Score: float64(now.UnixMilli()), Member: sessionID})
+}
+
+func (s *RedisStore) Append(sessionID string, m types.Message) error {
+	if sessionID == "" {
+		return errors.New("empty session id")
+	}
+	return s.insert(sessionID, nil, m)
+}
+
+func (s *RedisStore) Fork(sessionID, parentID string, m types.Message) error {
+	if sessionID == "" {
+		return errors.New("empty session id")
+	}
+	return s.insert(sessionID, &parentID, m)
+}
+
+// insert adds m under parentID, or under the current head when parentID is nil. The
+// head is read and moved under WATCH, so concurrent appends from other replicas retry
+// instead of both hanging off the same parent.
+func (s *RedisStore) insert(sessionID string, parentID *string, m types.Message) error {
+	ctx := context.Background()
+	if m.ID == "" {
+		m.ID = NewMessageID()
+	}
+	if m.Timestamp.IsZero() {
+		m.Timestamp = time.Now()
+	}
+
+	txf := func(tx *redis.Tx) error {
+		if parentID == nil {
+			head, err := tx.HGet(ctx, s.metaKey(sessionID), "head").Result()
+			if err != nil && !errors.Is(err, redis.Nil) {
+				return err
+			}
+			m.ParentID = head
+		} else {
+			m.ParentID = *parentID
+			if m.ParentID != "" {
+				ok, err := tx.SIsMember(ctx, s.idsKey(sessionID), m.ParentID).Result()
+				if err != nil {
+					return err
+				}
+				if !ok {
+					return ErrNotFound
+				}
+			}
+		}
+		data, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.RPush(ctx, s.msgsKey(sessionID), data)
+			p.SAdd(ctx, s.idsKey(sessionID), m.ID)
+			p.HSet(ctx, s.metaKey(sessionID), "head", m.ID)
+			s.touch(ctx, p, sessionID, time.Now())
+			return nil
+		})
+		return err
+	}
+
+	return s.watch(ctx, txf, s.metaKey(sessionID))
+}
+
+// watch runs txf under WATCH of keys, retrying while other clients change them.
+func (s *RedisStore) watch(ctx context.Context, txf func(*redis.Tx) error, keys ...string) error {
+	for i := 0; i < maxTxRetries; i++ {
+		err := s.rdb.Watch(ctx, txf, keys...)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return redis.TxFailedErr
+}
+
+func (s *RedisStore) Get(sessionID string) ([]types.Message, error) {
+	ctx := context.Background()
+	var (
+		msgs *redis.StringSliceCmd
+		head *redis.StringCmd
+	)
+	_, err := s.rdb.Pipelined(ctx, func(p redis.Pipeliner) error {
+		msgs = p.LRange(ctx, s.msgsKey(sessionID), 0, -1)
+		head = p.HGet(ctx, s.metaKey(sessionID), "head")
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	all, err := decodeMessages(msgs.Val())
+	if err != nil {
+		return nil, err
+	}
+	return Branch(all, head.Val()), nil
+}
+
+func (s *RedisStore) Tree(sessionID string) ([]types.Message, error) {
+	raw, err := s.rdb.LRange(context.Background(), s.msgsKey(sessionID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	return decodeMessages(raw)
+}
+
+func decodeMessages(raw []string) ([]types.Message, error) {
+	out := make([]types.Message, 0, len(raw))
+	for _, r := range raw {
+		var m types.Message
+		if err := json.Unmarshal([]byte(r), &m); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+// Checkout moves the head under WATCH of the message IDs, so a concurrent Delete
+// cannot leave the head pointing at a message that no longer exists.
+func (s *RedisStore) Checkout(sessionID, messageID string) error {
+	ctx := context.Background()
+	txf := func(tx *redis.Tx) error {
+		ok, err := tx.SIsMember(ctx, s.idsKey(sessionID), messageID).Result()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrNotFound
+		}
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.HSet(ctx, s.metaKey(sessionID), "head", messageID)
+			s.touch(ctx, p, sessionID, time.Now())
+			return nil
+		})
+		return err
+	}
+	return s.watch(ctx, txf, s.idsKey(sessionID), s.metaKey(sessionID))
+}
+
+func (s *RedisStore) SetSystemPrompt(sessionID, prompt string) error {
+	if sessionID == "" {
+		return errors.New("empty session id")
+	}
+	ctx := context.Background()
+	_, err := s.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		if prompt == "" {
+			p.HDel(ctx, s.metaKey(sessionID), "system")
+		} else {
+			p.HSet(ctx, s.metaKey(sessionID), "system", prompt)
+		}
+		s.touch(ctx, p, sessionID, time.Now())
+		return nil
+	})
+	return err
+}
+
+func (s *RedisStore) SystemPrompt(sessionID string) (string, error) {
+	prompt, err := s.rdb.HGet(context.Background(), s.metaKey(sessionID), "system").Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return prompt, err
+}
+
+// List reads every session's summary in one pipeline.
+func (s *RedisStore) List() ([]Summary, error) {
+	ctx := context.Background()
+	ids, err := s.rdb.ZRevRange(ctx, s.indexKey(), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	cmds := make([]metaCmds, len(ids))
+	_, err = s.rdb.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for i, id := range ids {
+			cmds[i] = s.queueMeta(ctx, p, id)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	out := make([]Summary, 0, len(ids))
+	for i, id := range ids {
+		sum, err := cmds[i].summary(id)
+		if errors.Is(err, ErrSessionNotFound) {
+			continue // deleted since ZREVRANGE
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, sum)
+	}
+	sortSummaries(out)
+	return out, nil
+}
+
+// Create adds an empty session unless it exists; the check runs under WATCH, so a
+// concurrent write to the session makes it look again.
+func (s *RedisStore) Create(sessionID string) error {
+	if sessionID == "" {
+		return errors.New("empty session id")
+	}
+	ctx := context.Background()
+	return s.watch(ctx, func(tx *redis.Tx) error {
+		ok, err := tx.Exists(ctx, s.metaKey(sessionID)).Result()
+		if err != nil || ok == 1 {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			s.touch(ctx, p, sessionID, time.Now())
+			return nil
+		})
+		return err
+	}, s.metaKey(sessionID))
+}
+
+func (s *RedisStore) Meta(sessionID string) (Summary, error) {
+	ctx := context.Background()
+	var cmds metaCmds
+	_, err := s.rdb.Pipelined(ctx, func(p redis.Pipeliner) error {
+		cmds = s.queueMeta(ctx, p, sessionID)
+		return nil
+	})
+	if err != nil {
+		return Summary{}, err
+	}
+	return cmds.summary(sessionID)
+}
+
+// metaCmds are the pipelined reads behind one Summary.
+type metaCmds struct {
+	meta  *redis.MapStringStringCmd
+	count *redis.IntCmd
+	first *redis.StringSliceCmd
+}
+
+func (s *RedisStore) queueMeta(ctx context.Context, p redis.Pipeliner, sessionID string) metaCmds {
+	return metaCmds{
+		meta:  p.HGetAll(ctx, s.metaKey(sessionID)),
+		count: p.LLen(ctx, s.msgsKey(sessionID)),
+		first: p.LRange(ctx, s.msgsKey(sessionID), 0, 1), // the first user message opens the tree
+	}
+}
+
+func (c metaCmds) summary(sessionID string) (Summary, error) {
+	m := c.meta.Val()
+	if len(m) == 0 {
+		return Summary{}, ErrSessionNotFound
+	}
+	msgs, err := decodeMessages(c.first.Val())
+	if err != nil {
+		return Summary{}, err
+	}
+	sum := Summary{ID: sessionID, Pinned: m["pinned"] == "1", Messages: int(c.count.Val())}
+	sum.Title, sum.Custom = summaryTitle(m["title"], msgs)
+	sum.Created, sum.Updated = unixNano(m["created"]), unixNano(m["updated"])
+	return sum, nil
+}
+
+func unixNano(v string) time.Time {
+	n, _ := strconv.ParseInt(v, 10, 64)
+	return time.Unix(0, n)
+}
+
+func (s *RedisStore) Rename(sessionID, title string) error {
+	return s.setMeta(sessionID, "title", title)
+}
+
+func (s *RedisStore) Pin(sessionID string, pinned bool) error {
+	v := "0"
+	if pinned {
+		v = "1"
+	}
+	return s.setMeta(sessionID, "pinned", v)
+}
+
+// setMeta sets one field of an existing session's hash, under WATCH so a concurrent
+// Delete is not undone by recreating the hash with just that field.
+func (s *RedisStore) setMeta(sessionID, field, value string) error {
+	ctx := context.Background()
+	return s.watch(ctx, func(tx *redis.Tx) error {
+		ok, err := tx.Exists(ctx, s.metaKey(sessionID)).Result()
+		if err != nil {
+			return err
+		}
+		if ok == 0 {
+			return ErrSessionNotFound
+		}
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.HSet(ctx, s.metaKey(sessionID), field, value)
+			return nil
+		})
+		return err
+	}, s.metaKey(sessionID))
+}
+
+func (s *RedisStore) Delete(sessionID string) error {
+	ctx := context.Background()
+	var del *redis.IntCmd
+	_, err := s.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		del = p.Del(ctx, s.metaKey(sessionID), s.msgsKey(sessionID), s.idsKey(sessionID))
+		p.ZRem(ctx, s.indexKey(), sessionID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if del.Val() == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// Search scans every session's messages: plain Redis has no full-text index, so this
+// costs O(total messages) and is meant for demo-sized data.
+func (s *RedisStore) Search(query string, limit int) ([]Hit, error) {
+	terms := ParseQuery(query)
+	if len(terms) == 0 {
+		return []Hit{}, nil
+	}
+	ctx := context.Background()
+	ids, err := s.rdb.ZRevRange(ctx, s.indexKey(), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	hits := []Hit{}
+	for _, id := range ids {
+		all, err := s.Tree(id)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range all {
+			if matches(m.Content, terms) {
+				hits = append(hits, newHit(id, m, terms))
+			}
+		}
+	}
+	return sortHits(hits, searchLimit(limit)), nil
+}
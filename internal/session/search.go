@@ -0,0 +1,140 @@
+package session
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/varsilias/zero-downtime/pkg/types"
+)
+
+// MaxSearchResults caps the hits of one Search call.
+const MaxSearchResults = 50
+
+// Hit is a message matching a search query.
+type Hit struct {
+	SessionID string     `json:"session_id"`
+	MessageID string     `json:"message_id"`
+	Role      types.Role `json:"role"`
+	Snippet   string     `json:"snippet"`
+	Timestamp time.Time  `json:"timestamp"`
+}
+
+// ParseQuery splits a search query into lowercase terms. Every store matches a message
+// when it contains all terms as words, the last one as a prefix (search as you type).
+func ParseQuery(q string) []string {
+	var terms []string
+	for _, w := range tokens(q) {
+		terms = append(terms, w.text)
+	}
+	return terms
+}
+
+type token struct {
+	text  string // lowercased
+	start int    // rune offset in the source
+}
+
+// tokens splits s on anything that is not a letter or a digit, like SQLite's
+// unicode61 tokenizer.
+func tokens(s string) []token {
+	var (
+		out   []token
+		cur   []rune
+		start int
+	)
+	i := 0
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if len(cur) == 0 {
+				start = i
+			}
+			cur = append(cur, unicode.ToLower(r))
+		} else if len(cur) > 0 {
+			out = append(out, token{text: string(cur), start: start})
+			cur = cur[:0]
+		}
+		i++
+	}
+	if len(cur) > 0 {
+		out = append(out, token{text: string(cur), start: start})
+	}
+	return out
+}
+
+// termMatches reports whether w matches the i-th of terms.
+func termMatches(w string, terms []string, i int) bool {
+	if i == len(terms)-1 {
+		return strings.HasPrefix(w, terms[i])
+	}
+	return w == terms[i]
+}
+
+// matches reports whether content contains every term.
+func matches(content string, terms []string) bool {
+	ws := tokens(content)
+	for i := range terms {
+		found := false
+		for _, w := range ws {
+			if termMatches(w.text, terms, i) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// snippet returns a short single-line excerpt of content around the first matching term.
+func snippet(content string, terms []string) string {
+	const before, after = 40, 120
+	runes := []rune(content)
+	at := 0
+first:
+	for _, w := range tokens(content) {
+		for i := range terms {
+			if termMatches(w.text, terms, i) {
+				at = w.start
+				break first
+			}
+		}
+	}
+	from, to := max(at-before, 0), min(at+after, len(runes))
+	s := strings.Join(strings.Fields(string(runes[from:to])), " ")
+	if from > 0 {
+		s = "…" + s
+	}
+	if to < len(runes) {
+		s += "…"
+	}
+	return s
+}
+
+// newHit builds the Hit for a matching message.
+func newHit(sessionID string, m types.Message, terms []string) Hit {
+	return Hit{SessionID: sessionID, MessageID: m.ID, Role: m.Role, Snippet: snippet(m.Content, terms), Timestamp: m.Timestamp}
+}
+
+// sortHits orders hits newest first and applies limit.
+func sortHits(hits []Hit, limit int) []Hit {
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Timestamp.After(hits[j].Timestamp) })
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// searchLimit clamps a requested result count to 1..MaxSearchResults (0 means 20).
+func searchLimit(limit int) int {
+	switch {
+	case limit <= 0:
+		return 20
+	case limit > MaxSearchResults:
+		return MaxSearchResults
+	}
+	return limit
+}
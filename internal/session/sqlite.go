@@ -0,0 +1,398 @@
+package session
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/varsilias/zero-downtime/pkg/types"
+	_ "modernc.org/sqlite" // pure-Go driver, keeps CGO_ENABLED=0 builds working
+)
+
+// migrations are applied in order; each entry is one schema version. Never edit a
+// released entry, append a new one instead.
+var migrations = []string{
+	// 1: sessions and their message trees
+	`CREATE TABLE sessions (
+		id            TEXT PRIMARY KEY,
+		head          TEXT NOT NULL DEFAULT '',
+		system_prompt TEXT NOT NULL DEFAULT '',
+		created_at    INTEGER NOT NULL,
+		updated_at    INTEGER NOT NULL
+	);
+	CREATE INDEX idx_sessions_updated_at ON sessions(updated_at DESC);
+
+	CREATE TABLE messages (
+		seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+		session_id TEXT NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+		id         TEXT NOT NULL,
+		parent_id  TEXT NOT NULL DEFAULT '',
+		role       TEXT NOT NULL,
+		content    TEXT NOT NULL,
+		created_at INTEGER NOT NULL,
+		data       TEXT NOT NULL DEFAULT '{}'
+	);
+	CREATE UNIQUE INDEX idx_messages_session_id ON messages(session_id, id);
+	CREATE INDEX idx_messages_session_seq ON messages(session_id, seq);`,
+
+	// 2: sidebar metadata
+	`ALTER TABLE sessions ADD COLUMN title TEXT NOT NULL DEFAULT '';
+	ALTER TABLE sessions ADD COLUMN pinned INTEGER NOT NULL DEFAULT 0;
+	CREATE INDEX idx_sessions_pinned_updated_at ON sessions(pinned DESC, updated_at DESC);`,
+
+	// 3: full-text search over message content, kept in sync by triggers
+	`CREATE VIRTUAL TABLE messages_fts USING fts5(content, content='messages', content_rowid='seq');
+	CREATE TRIGGER messages_fts_insert AFTER INSERT ON messages BEGIN
+		INSERT INTO messages_fts(rowid, content) VALUES (new.seq, new.content);
+	END;
+	CREATE TRIGGER messages_fts_delete AFTER DELETE ON messages BEGIN
+		INSERT INTO messages_fts(messages_fts, rowid, content) VALUES ('delete', old.seq, old.content);
+	END;
+	CREATE TRIGGER messages_fts_update AFTER UPDATE OF content ON messages BEGIN
+		INSERT INTO messages_fts(messages_fts, rowid, content) VALUES ('delete', old.seq, old.content);
+		INSERT INTO messages_fts(rowid, content) VALUES (new.seq, new.content);
+	END;
+	INSERT INTO messages_fts(messages_fts) VALUES ('rebuild');`,
+}
+
+// SQLiteStore is a Store on an embedded SQLite database, so conversations survive
+// restarts and rolling updates (as long as the file sits on a persistent volume).
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore opens (or creates) the database at path and applies pending migrations.
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// one writer at a time; SQLite serializes writes anyway and this avoids SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	s := &SQLiteStore{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("sqlite migrate: %w", err)
+	}
+	return s, nil
+}
+
+// Close releases the database.
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLiteStore) migrate() error {
+	if _, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at INTEGER NOT NULL
+	)`); err != nil {
+		return err
+	}
+	var current int
+	if err := s.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return err
+	}
+	for v := current + 1; v <= len(migrations); v++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[v-1]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("version %d: %w", v, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations(version, applied_at) VALUES(?, ?)`, v, time.Now().Unix()); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// touch creates the session row if needed and bumps updated_at.
+func touch(tx *sql.Tx, sessionID string, now time.Time) error {
+	_, err := tx.Exec(`INSERT INTO sessions(id, created_at, updated_at) VALUES(?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET updated_at = excluded.updated_at`, sessionID, now.UnixNano(), now.UnixNano())
+	return err
+}
+
+func (s *SQLiteStore) Append(sessionID string, m types.Message) error {
+	if sessionID == "" {
+		return errors.New("empty session id")
+	}
+	return s.insert(sessionID, nil, m)
+}
+
+func (s *SQLiteStore) Fork(sessionID, parentID string, m types.Message) error {
+	if sessionID == "" {
+		return errors.New("empty session id")
+	}
+	return s.insert(sessionID, &parentID, m)
+}
+
+// insert adds m under parentID, or under the current head when parentID is nil.
+func (s *SQLiteStore) insert(sessionID string, parentID *string, m types.Message) error {
+	now := time.Now()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := touch(tx, sessionID, now); err != nil {
+		return err
+	}
+	if parentID == nil {
+		var head string
+		if err := tx.QueryRow(`SELECT head FROM sessions WHERE id = ?`, sessionID).Scan(&head); err != nil {
+			return err
+		}
+		m.ParentID = head
+	} else {
+		m.ParentID = *parentID
+		if m.ParentID != "" {
+			var n int
+			if err := tx.QueryRow(`SELECT COUNT(*) FROM messages WHERE session_id = ? AND id = ?`, sessionID, m.ParentID).Scan(&n); err != nil {
+				return err
+			}
+			if n == 0 {
+				return ErrNotFound
+			}
+		}
+	}
+	if m.ID == "" {
+		m.ID = NewMessageID()
+	}
+	if m.Timestamp.IsZero() {
+		m.Timestamp = now
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO messages(session_id, id, parent_id, role, content, created_at, data) VALUES(?, ?, ?, ?, ?, ?, ?)`,
+		sessionID, m.ID, m.ParentID, string(m.Role), m.Content, m.Timestamp.UnixNano(), string(data)); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE sessions SET head = ? WHERE id = ?`, m.ID, sessionID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) Get(sessionID string) ([]types.Message, error) {
+	all, err := s.Tree(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	var head string
+	err = s.db.QueryRow(`SELECT head FROM sessions WHERE id = ?`, sessionID).Scan(&head)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	return Branch(all, head), nil
+}
+
+func (s *SQLiteStore) Tree(sessionID string) ([]types.Message, error) {
+	rows, err := s.db.Query(`SELECT id, parent_id, role, content, created_at, data FROM messages WHERE session_id = ? ORDER BY seq`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []types.Message
+	for rows.Next() {
+		var (
+			m       types.Message
+			role    string
+			created int64
+			data    string
+		)
+		if err := rows.Scan(&m.ID, &m.ParentID, &role, &m.Content, &created, &data); err != nil {
+			return nil, err
+		}
+		// data carries the optional fields (context, stopped, …); the columns are authoritative
+		id, parent, content := m.ID, m.ParentID, m.Content
+		if err := json.Unmarshal([]byte(data), &m); err != nil {
+			return nil, err
+		}
+		m.ID, m.ParentID, m.Content, m.Role = id, parent, content, types.Role(role)
+		m.Timestamp = time.Unix(0, created)
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+func (s *SQLiteStore) Checkout(sessionID, messageID string) error {
+	res, err := s.db.Exec(`UPDATE sessions SET head = ?, updated_at = ? WHERE id = ?
+		AND EXISTS (SELECT 1 FROM messages WHERE session_id = ? AND id = ?)`,
+		messageID, time.Now().UnixNano(), sessionID, sessionID, messageID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteStore) SetSystemPrompt(sessionID, prompt string) error {
+	if sessionID == "" {
+		return errors.New("empty session id")
+	}
+	now := time.Now()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := touch(tx, sessionID, now); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE sessions SET system_prompt = ? WHERE id = ?`, prompt, sessionID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) SystemPrompt(sessionID string) (string, error) {
+	var prompt string
+	err := s.db.QueryRow(`SELECT system_prompt FROM sessions WHERE id = ?`, sessionID).Scan(&prompt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return prompt, err
+}
+
+// summarySelect reads one Summary per session row; the first user message is only
+// fetched for untitled sessions.
+const summarySelect = `SELECT s.id, s.title, s.pinned, s.created_at, s.updated_at,
+	(SELECT COUNT(*) FROM messages m WHERE m.session_id = s.id),
+	CASE WHEN s.title = '' THEN COALESCE((SELECT m.content FROM messages m
+		WHERE m.session_id = s.id AND m.role = 'user' ORDER BY m.seq LIMIT 1), '') ELSE '' END
+	FROM sessions s`
+
+func scanSummary(row interface{ Scan(...any) error }) (Summary, error) {
+	var (
+		sum              Summary
+		title, first     string
+		created, updated int64
+	)
+	if err := row.Scan(&sum.ID, &title, &sum.Pinned, &created, &updated, &sum.Messages, &first); err != nil {
+		return Summary{}, err
+	}
+	sum.Title, sum.Custom = summaryTitle(title, []types.Message{{Role: types.RoleUser, Content: first}})
+	sum.Created, sum.Updated = time.Unix(0, created), time.Unix(0, updated)
+	return sum, nil
+}
+
+func (s *SQLiteStore) List() ([]Summary, error) {
+	rows, err := s.db.Query(summarySelect + ` ORDER BY s.pinned DESC, s.updated_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []Summary{}
+	for rows.Next() {
+		sum, err := scanSummary(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, sum)
+	}
+	return out, rows.Err()
+}
+
+func (s *SQLiteStore) Create(sessionID string) error {
+	if sessionID == "" {
+		return errors.New("empty session id")
+	}
+	now := time.Now().UnixNano()
+	_, err := s.db.Exec(`INSERT INTO sessions(id, created_at, updated_at) VALUES(?, ?, ?) ON CONFLICT(id) DO NOTHING`, sessionID, now, now)
+	return err
+}
+
+func (s *SQLiteStore) Meta(sessionID string) (Summary, error) {
+	sum, err := scanSummary(s.db.QueryRow(summarySelect+` WHERE s.id = ?`, sessionID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Summary{}, ErrSessionNotFound
+	}
+	return sum, err
+}
+
+func (s *SQLiteStore) Rename(sessionID, title string) error {
+	return s.updateSession(`UPDATE sessions SET title = ? WHERE id = ?`, title, sessionID)
+}
+
+func (s *SQLiteStore) Pin(sessionID string, pinned bool) error {
+	return s.updateSession(`UPDATE sessions SET pinned = ? WHERE id = ?`, pinned, sessionID)
+}
+
+func (s *SQLiteStore) Delete(sessionID string) error {
+	// messages go with it through ON DELETE CASCADE
+	return s.updateSession(`DELETE FROM sessions WHERE id = ?`, sessionID)
+}
+
+// updateSession runs a single-row statement and maps "no row" to ErrSessionNotFound.
+func (s *SQLiteStore) updateSession(query string, args ...any) error {
+	res, err := s.db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Search(query string, limit int) ([]Hit, error) {
+	terms := ParseQuery(query)
+	if len(terms) == 0 {
+		return []Hit{}, nil
+	}
+	// terms hold only letters and digits, so quoting them is enough to escape FTS syntax
+	match := make([]string, len(terms))
+	for i, t := range terms {
+		match[i] = `"` + t + `"`
+	}
+	match[len(match)-1] += "*"
+
+	rows, err := s.db.Query(`SELECT m.session_id, m.id, m.role, m.content, m.created_at
+		FROM messages_fts JOIN messages m ON m.seq = messages_fts.rowid
+		WHERE messages_fts MATCH ? ORDER BY m.created_at DESC LIMIT ?`,
+		strings.Join(match, " "), searchLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	hits := []Hit{}
+	for rows.Next() {
+		var (
+			sessionID, role string
+			m               types.Message
+			created         int64
+		)
+		if err := rows.Scan(&sessionID, &m.ID, &role, &m.Content, &created); err != nil {
+			return nil, err
+		}
+		m.Role, m.Timestamp = types.Role(role), time.Unix(0, created)
+		hits = append(hits, newHit(sessionID, m, terms))
+	}
+	return hits, rows.Err()
+}
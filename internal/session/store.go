@@ -0,0 +1,112 @@
+package session
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/varsilias/zero-downtime/pkg/types"
+)
+
+// ErrNotFound is returned when a message ID does not exist in the session.
+var ErrNotFound = errors.New("message not found")
+
+// ErrSessionNotFound is returned by session-level operations on an unknown session.
+var ErrSessionNotFound = errors.New("session not found")
+
+// MaxTitle bounds custom session titles, in bytes.
+const MaxTitle = 120
+
+// Store persists sessions as message trees (see tree.go). Get and Append work on the
+// active branch, so callers that ignore branching see a plain linear history.
+type Store interface {
+	// Append adds m under the active branch's leaf and makes it the new leaf.
+	// A missing m.ID is generated.
+	Append(sessionID string, m types.Message) error
+	// Get returns the active branch, root first.
+	Get(sessionID string) ([]types.Message, error)
+	// Fork adds m as a child of parentID ("" for a new root) and makes it the active leaf.
+	Fork(sessionID, parentID string, m types.Message) error
+	// Tree returns every message of the session, in insertion order.
+	Tree(sessionID string) ([]types.Message, error)
+	// Checkout makes messageID the leaf of the active branch.
+	Checkout(sessionID, messageID string) error
+	// SetSystemPrompt stores the session's system prompt; empty clears it.
+	SetSystemPrompt(sessionID, prompt string) error
+	SystemPrompt(sessionID string) (string, error)
+
+	// List returns every session, pinned first, then most recently updated.
+	List() ([]Summary, error)
+	// Create registers an empty session so it is listed; an existing session is left as is.
+	Create(sessionID string) error
+	// Meta returns the session's summary, or ErrSessionNotFound.
+	Meta(sessionID string) (Summary, error)
+	// Rename sets a custom title; empty reverts to the one derived from the first message.
+	Rename(sessionID, title string) error
+	Pin(sessionID string, pinned bool) error
+	// Delete removes the session with all of its messages.
+	Delete(sessionID string) error
+
+	// Search finds messages of every session and branch containing all terms of query
+	// (see ParseQuery), newest first, at most limit (capped at MaxSearchResults).
+	Search(query string, limit int) ([]Hit, error)
+}
+
+// Summary is a session's sidebar entry.
+type Summary struct {
+	ID       string    `json:"id"`
+	Title    string    `json:"title"`        // custom title, else derived from the first user message
+	Custom   bool      `json:"custom_title"` // Title was set through Rename
+	Pinned   bool      `json:"pinned"`
+	Messages int       `json:"messages"`          // across all branches
+	Trimmed  int       `json:"trimmed,omitempty"` // oldest messages dropped to stay within a per-session cap
+	Created  time.Time `json:"created"`
+	Updated  time.Time `json:"updated"` // last new message, checkout or system prompt change
+}
+
+// sortSummaries orders sessions the way List returns them.
+func sortSummaries(out []Summary) {
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Pinned != out[j].Pinned {
+			return out[i].Pinned
+		}
+		return out[i].Updated.After(out[j].Updated)
+	})
+}
+
+// summaryTitle picks the custom title if set, else derives one from msgs.
+func summaryTitle(custom string, msgs []types.Message) (string, bool) {
+	if custom != "" {
+		return custom, true
+	}
+	return titleFrom(msgs), false
+}
+
+func titleFrom(msgs []types.Message) string {
+	for _, m := range msgs {
+		if m.Role == types.RoleUser {
+			return clip(words(m.Content), 8)
+		}
+	}
+	return ""
+}
+
+func words(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return s
+	}
+	parts := strings.Fields(s)
+	if len(parts) <= 12 {
+		return s
+	}
+	return strings.Join(parts[:12], " ")
+}
+
+func clip(s string, n int) string {
+	if len(s) <= n*2 {
+		return s
+	}
+	return s[:n*2] + "…"
+}
@@ -0,0 +1,286 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/varsilias/zero-downtime/pkg/types"
+)
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, func(t *testing.T) Store {
+		s := NewMemoryStore(MemoryLimits{})
+		t.Cleanup(func() { s.Close() })
+		return s
+	})
+}
+
+func TestSQLiteStore(t *testing.T) {
+	testStore(t, func(t *testing.T) Store {
+		s, err := NewSQLiteStore(filepath.Join(t.TempDir(), "sessions.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.Close() })
+		return s
+	})
+}
+
+// TestRedisStore runs the contract against miniredis, an in-process Redis stand-in
+// that supports the WATCH/MULTI transactions the store relies on.
+func TestRedisStore(t *testing.T) {
+	testStore(t, func(t *testing.T) Store {
+		mr := miniredis.RunT(t)
+		return NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}), "zd:")
+	})
+}
+
+// TestRedisMetaDeleteRace deletes the session from another replica right after
+// Rename/Pin found it: the write must not bring back a hash with just that field.
+func TestRedisMetaDeleteRace(t *testing.T) {
+	for name, set := range map[string]func(s *RedisStore) error{
+		"rename": func(s *RedisStore) error { return s.Rename("s1", "renamed") },
+		"pin":    func(s *RedisStore) error { return s.Pin("s1", true) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			other := NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}), "zd:")
+			rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			s := NewRedisStore(rdb, "zd:")
+			mustAppend(t, s, "s1", types.RoleUser, "hello")
+
+			rdb.AddHook(&afterExists{fn: func() {
+				if err := other.Delete("s1"); err != nil {
+					t.Error(err)
+				}
+			}})
+			if err := set(s); !errors.Is(err, ErrSessionNotFound) {
+				t.Fatalf("set on a session deleted meanwhile: %v, want ErrSessionNotFound", err)
+			}
+			if sum, err := s.Meta("s1"); !errors.Is(err, ErrSessionNotFound) {
+				t.Fatalf("Meta after the race = %+v, %v; want ErrSessionNotFound", sum, err)
+			}
+			if keys := mr.Keys(); len(keys) != 0 {
+				t.Fatalf("keys left after the race: %v", keys)
+			}
+		})
+	}
+}
+
+// afterExists runs fn once, right after the first EXISTS the client sends.
+type afterExists struct {
+	fn   func()
+	once sync.Once
+}
+
+func (h *afterExists) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (h *afterExists) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		err := next(ctx, cmd)
+		if cmd.Name() == "exists" {
+			h.once.Do(h.fn)
+		}
+		return err
+	}
+}
+
+func (h *afterExists) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+// testStore is the behaviour every Store implementation must share.
+func testStore(t *testing.T, newStore func(t *testing.T) Store) {
+	t.Run("linear history", func(t *testing.T) {
+		s := newStore(t)
+		mustAppend(t, s, "s1", types.RoleUser, "hello there")
+		mustAppend(t, s, "s1", types.RoleAssistant, "hi")
+		got := mustGet(t, s, "s1")
+		if len(got) != 2 || got[0].Content != "hello there" || got[1].Content != "hi" {
+			t.Fatalf("Get = %v", contents(got))
+		}
+		if got[0].ParentID != "" || got[1].ParentID != got[0].ID {
+			t.Fatalf("parents: %q -> %q, want root -> %q", got[0].ParentID, got[1].ParentID, got[0].ID)
+		}
+		if msgs := mustGet(t, s, "nope"); len(msgs) != 0 {
+			t.Fatalf("unknown session: Get = %v, want empty", contents(msgs))
+		}
+	})
+
+	t.Run("fork and checkout", func(t *testing.T) {
+		s := newStore(t)
+		mustAppend(t, s, "s1", types.RoleUser, "q")
+		first := mustAppend(t, s, "s1", types.RoleAssistant, "a1")
+		root := mustGet(t, s, "s1")[0]
+
+		// regenerate: a sibling of a1 becomes the active leaf
+		if err := s.Fork("s1", root.ID, types.Message{Role: types.RoleAssistant, Content: "a2"}); err != nil {
+			t.Fatal(err)
+		}
+		if got := contents(mustGet(t, s, "s1")); !equal(got, []string{"q", "a2"}) {
+			t.Fatalf("after fork: Get = %v", got)
+		}
+		all, err := s.Tree("s1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := contents(all); !equal(got, []string{"q", "a1", "a2"}) {
+			t.Fatalf("Tree = %v", got)
+		}
+
+		if err := s.Checkout("s1", first.ID); err != nil {
+			t.Fatal(err)
+		}
+		if got := contents(mustGet(t, s, "s1")); !equal(got, []string{"q", "a1"}) {
+			t.Fatalf("after checkout: Get = %v", got)
+		}
+		// appends continue from the checked-out branch
+		mustAppend(t, s, "s1", types.RoleUser, "more")
+		if got := contents(mustGet(t, s, "s1")); !equal(got, []string{"q", "a1", "more"}) {
+			t.Fatalf("append after checkout: Get = %v", got)
+		}
+
+		if err := s.Checkout("s1", "missing"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Checkout(missing) = %v, want ErrNotFound", err)
+		}
+		if err := s.Fork("s1", "missing", types.Message{Role: types.RoleUser, Content: "x"}); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Fork(missing parent) = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("system prompt", func(t *testing.T) {
+		s := newStore(t)
+		if p, err := s.SystemPrompt("s1"); err != nil || p != "" {
+			t.Fatalf("unset: %q, %v", p, err)
+		}
+		if err := s.SetSystemPrompt("s1", "be brief"); err != nil {
+			t.Fatal(err)
+		}
+		if p, _ := s.SystemPrompt("s1"); p != "be brief" {
+			t.Fatalf("SystemPrompt = %q", p)
+		}
+		if err := s.SetSystemPrompt("s1", ""); err != nil {
+			t.Fatal(err)
+		}
+		if p, _ := s.SystemPrompt("s1"); p != "" {
+			t.Fatalf("cleared: SystemPrompt = %q", p)
+		}
+	})
+
+	t.Run("list, rename, pin, delete", func(t *testing.T) {
+		s := newStore(t)
+		if err := s.Create("empty"); err != nil {
+			t.Fatal(err)
+		}
+		mustAppend(t, s, "chat", types.RoleUser, "rollout help")
+		mustAppend(t, s, "chat", types.RoleAssistant, "one pod at a time")
+
+		sum, err := s.Meta("chat")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sum.Title != "rollout help" || sum.Custom || sum.Messages != 2 || sum.Pinned {
+			t.Fatalf("Meta = %+v", sum)
+		}
+		if _, err := s.Meta("nope"); !errors.Is(err, ErrSessionNotFound) {
+			t.Fatalf("Meta(nope) = %v, want ErrSessionNotFound", err)
+		}
+
+		if err := s.Rename("chat", "Rollouts"); err != nil {
+			t.Fatal(err)
+		}
+		if sum, _ := s.Meta("chat"); sum.Title != "Rollouts" || !sum.Custom {
+			t.Fatalf("after Rename: %+v", sum)
+		}
+		if err := s.Rename("chat", ""); err != nil {
+			t.Fatal(err)
+		}
+		if sum, _ := s.Meta("chat"); sum.Title != "rollout help" || sum.Custom {
+			t.Fatalf("after clearing the title: %+v", sum)
+		}
+
+		// pinned sessions list first, whatever their update time
+		if err := s.Pin("empty", true); err != nil {
+			t.Fatal(err)
+		}
+		list, err := s.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 2 || list[0].ID != "empty" || !list[0].Pinned || list[1].ID != "chat" {
+			t.Fatalf("List = %+v", list)
+		}
+		if err := s.Pin("nope", true); !errors.Is(err, ErrSessionNotFound) {
+			t.Fatalf("Pin(nope) = %v, want ErrSessionNotFound", err)
+		}
+
+		if err := s.Delete("chat"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Delete("chat"); !errors.Is(err, ErrSessionNotFound) {
+			t.Fatalf("second Delete = %v, want ErrSessionNotFound", err)
+		}
+		if msgs := mustGet(t, s, "chat"); len(msgs) != 0 {
+			t.Fatalf("deleted session still has %v", contents(msgs))
+		}
+		if list, _ := s.List(); len(list) != 1 || list[0].ID != "empty" {
+			t.Fatalf("List after Delete = %+v", list)
+		}
+	})
+
+	t.Run("search", func(t *testing.T) {
+		s := newStore(t)
+		mustAppend(t, s, "a", types.RoleUser, "kubectl rollout status")
+		mustAppend(t, s, "b", types.RoleUser, "redis sentinel")
+		hits, err := s.Search("rollout", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(hits) != 1 || hits[0].SessionID != "a" {
+			t.Fatalf("Search = %+v", hits)
+		}
+	})
+}
+
+func mustAppend(t *testing.T, s Store, sessionID string, role types.Role, content string) types.Message {
+	t.Helper()
+	m := types.Message{ID: NewMessageID(), Role: role, Content: content}
+	if err := s.Append(sessionID, m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func mustGet(t *testing.T, s Store, sessionID string) []types.Message {
+	t.Helper()
+	msgs, err := s.Get(sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msgs
+}
+
+func contents(msgs []types.Message) []string {
+	out := make([]string, 0, len(msgs))
+	for _, m := range msgs {
+		out = append(out, m.Content)
+	}
+	return out
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
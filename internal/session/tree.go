@@ -0,0 +1,91 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/varsilias/zero-downtime/pkg/types"
+)
+
+// Sessions are trees: every message points at its parent and the store keeps a head
+// (the leaf of the active branch). The helpers below work on a session's messages in
+// insertion order, so every Store implementation can share them.
+
+// NewMessageID returns a random message ID.
+func NewMessageID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// Branch returns the path from the root to leafID, root first.
+func Branch(all []types.Message, leafID string) []types.Message {
+	byID := make(map[string]types.Message, len(all))
+	for _, m := range all {
+		byID[m.ID] = m
+	}
+	var path []types.Message
+	for id := leafID; id != ""; {
+		m, ok := byID[id]
+		if !ok {
+			break
+		}
+		path = append(path, m)
+		id = m.ParentID
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// LatestLeaf follows the most recently added child from id down to a leaf.
+func LatestLeaf(all []types.Message, id string) string {
+	for {
+		next := ""
+		for _, m := range all {
+			if m.ParentID == id && m.ID != "" {
+				next = m.ID // later entries win: they were added more recently
+			}
+		}
+		if next == "" {
+			return id
+		}
+		id = next
+	}
+}
+
+// Siblings returns the IDs of the messages that share id's parent (id included),
+// in insertion order.
+func Siblings(all []types.Message, id string) []string {
+	parent, found := "", false
+	for _, m := range all {
+		if m.ID == id {
+			parent, found = m.ParentID, true
+			break
+		}
+	}
+	if !found {
+		return nil
+	}
+	var out []string
+	for _, m := range all {
+		if m.ParentID == parent {
+			out = append(out, m.ID)
+		}
+	}
+	return out
+}
+
+// Find returns the message with the given ID.
+func Find(all []types.Message, id string) (types.Message, bool) {
+	for _, m := range all {
+		if m.ID == id {
+			return m, true
+		}
+	}
+	return types.Message{}, false
+}
@@ -0,0 +1,128 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"github.com/varsilias/zero-downtime/internal/buildinfo"
+	"github.com/varsilias/zero-downtime/internal/ollama"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// EnableAdmin adds the loaded-models page (/ui/admin) backed by oc; call it before
+// RegisterRoutes, and only when Ollama serves the chat.
+func (u *UI) EnableAdmin(oc ollama.API) { u.ollama = oc }
+
+type loadedVM struct {
+	Models []loadedModel
+	Pooled bool   // several instances: show the endpoint column
+	Error  string // of the last action or of /api/ps
+}
+
+type loadedModel struct {
+	Name         string
+	Endpoint     string
+	Family       string
+	Quantization string
+	Memory       string // total, e.g. "1.1 GB"
+	VRAM         string // share on the GPU, e.g. "100%"; empty on CPU
+	Expires      string // "in 4m12s", "never"
+	ExpiresAt    string
+}
+
+// Admin shows which models are resident, with unload buttons and a warm-up form.
+func (u *UI) Admin(w http.ResponseWriter, r *http.Request) {
+	mods, _ := u.models.List(r.Context())
+	u.render(w, "admin.html", map[string]any{
+		"Loaded":  u.loaded(r.Context(), ""),
+		"Models":  modelGroups(mods),
+		"Version": buildinfo.Version,
+		"Commit":  buildinfo.Commit,
+		"BuiltAt": buildinfo.BuiltAt,
+		"Backend": u.backend(),
+	}, http.StatusOK)
+}
+
+// Loaded returns the resident-models table; the page polls it.
+func (u *UI) Loaded(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	u.render(w, "loaded.html", u.loaded(r.Context(), ""), http.StatusOK)
+}
+
+// UnloadPost evicts the form's model and returns the refreshed table.
+func (u *UI) UnloadPost(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	model := r.Form.Get("model")
+	msg := ""
+	if err := ollama.ValidName(model); err != nil {
+		msg = err.Error()
+	} else if err := u.ollama.Unload(r.Context(), model); err != nil {
+		msg = "unload " + model + ": " + err.Error()
+	}
+	u.render(w, "loaded.html", u.loaded(r.Context(), msg), http.StatusOK)
+}
+
+// WarmupPost loads the form's model and returns the refreshed table.
+func (u *UI) WarmupPost(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	model := r.Form.Get("model")
+	msg := ""
+	if err := ollama.ValidName(model); err != nil {
+		msg = err.Error()
+	} else if _, err := u.ollama.Load(r.Context(), model); err != nil {
+		msg = "warm up " + model + ": " + err.Error()
+	}
+	u.render(w, "loaded.html", u.loaded(r.Context(), msg), http.StatusOK)
+}
+
+// loaded builds the table from /api/ps; msg is an error to show above it.
+func (u *UI) loaded(ctx context.Context, msg string) loadedVM {
+	vm := loadedVM{Error: msg}
+	running, err := u.ollama.PS(ctx)
+	if err != nil {
+		if vm.Error != "" {
+			vm.Error += "; "
+		}
+		vm.Error += "list loaded models: " + err.Error()
+		return vm
+	}
+	now := time.Now()
+	for _, m := range running {
+		lm := loadedModel{
+			Name:         m.Name,
+			Endpoint:     m.Endpoint,
+			Family:       m.Details.Family,
+			Quantization: m.Details.QuantizationLevel,
+			Memory:       humanSize(m.Size),
+			Expires:      expiresIn(m.ExpiresAt, now),
+			ExpiresAt:    m.ExpiresAt.Local().Format(time.DateTime),
+		}
+		if m.SizeVRAM > 0 && m.Size > 0 {
+			lm.VRAM = humanPercent(m.SizeVRAM, m.Size)
+		}
+		if m.Endpoint != "" {
+			vm.Pooled = true
+		}
+		vm.Models = append(vm.Models, lm)
+	}
+	// stable rows for the poll; a pool already sorts, a single instance may not
+	sort.SliceStable(vm.Models, func(i, j int) bool { return vm.Models[i].Name < vm.Models[j].Name })
+	return vm
+}
+
+// expiresIn says when an idle model unloads; keep_alive -1 shows as a date centuries away.
+func expiresIn(at, now time.Time) string {
+	d := at.Sub(now)
+	switch {
+	case at.IsZero() || d > 100*365*24*time.Hour:
+		return "never"
+	case d <= 0:
+		return "unloading"
+	}
+	return "in " + d.Round(time.Second).String()
+}
+
+func humanPercent(part, whole int64) string {
+	return fmt.Sprintf("%.0f%%", 100*float64(part)/float64(whole))
+}
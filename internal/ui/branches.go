@@ -0,0 +1,164 @@
+package ui
+
+import (
+	"errors"
+	"github.com/varsilias/zero-downtime/internal/chat"
+	"github.com/varsilias/zero-downtime/internal/session"
+	"github.com/varsilias/zero-downtime/pkg/types"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// historyViews renders the active branch of a session, with sibling navigation,
+// edit/regenerate affordances and the context-window markers of the latest reply.
+func (u *UI) historyViews(sid string) []MsgView {
+	msgs, _ := u.sessions.Get(sid)
+	all, _ := u.sessions.Tree(sid)
+	return u.branchViews(msgs, all)
+}
+
+// branchViews renders msgs, a branch of the message tree all.
+func (u *UI) branchViews(msgs, all []types.Message) []MsgView {
+	hist := make([]MsgView, 0, len(msgs))
+	for _, m := range msgs {
+		v := MsgView{ID: m.ID, Content: m.Content, Role: string(m.Role), HTML: u.mdHTML(m.Content), Context: m.Context, Stopped: m.Stopped}
+		if sibs := session.Siblings(all, m.ID); len(sibs) > 1 {
+			for i, id := range sibs {
+				if id != m.ID {
+					continue
+				}
+				v.SiblingPos, v.SiblingCount = i+1, len(sibs)
+				if i > 0 {
+					v.PrevSibling = sibs[i-1]
+				}
+				if i < len(sibs)-1 {
+					v.NextSibling = sibs[i+1]
+				}
+			}
+		}
+		hist = append(hist, v)
+	}
+
+	// dim the messages the latest reply was generated without
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Role != types.RoleAssistant {
+			continue
+		}
+		hist[i].CanRegenerate = i == len(msgs)-1
+		if c := msgs[i].Context; c != nil {
+			for _, d := range c.Dropped {
+				if d >= 0 && d < len(hist) {
+					hist[d].Dropped = true
+				}
+			}
+		}
+		break
+	}
+	return hist
+}
+
+// RegeneratePost re-renders the active branch up to the last user message and streams
+// a new answer to it. The replaced answer stays reachable as a sibling.
+func (u *UI) RegeneratePost(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	sid, model := r.Form.Get("session_id"), r.Form.Get("model")
+	if sid == "" || model == "" {
+		http.Error(w, "bad request", 400)
+		return
+	}
+	opts, err := parseOptionsForm(r.Form)
+	if err == nil {
+		err = opts.Validate()
+	}
+	if err != nil {
+		http.Error(w, "invalid options: "+err.Error(), 400)
+		return
+	}
+
+	hist := u.historyViews(sid)
+	last := -1
+	for i := len(hist) - 1; i >= 0; i-- {
+		if hist[i].Role == string(types.RoleUser) {
+			last = i
+			break
+		}
+	}
+	if last < 0 {
+		http.Error(w, chat.ErrNothingToRegenerate.Error(), 400)
+		return
+	}
+
+	id := u.pending.put(pendingChat{Kind: pendingRegenerate, SessionID: sid, Model: model, Options: opts})
+	u.renderBranch(w, hist[:last+1], id)
+}
+
+// EditPost forks an edited copy of a user message and streams the answer to it.
+func (u *UI) EditPost(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	sid, model, mid := r.Form.Get("session_id"), r.Form.Get("model"), r.Form.Get("message_id")
+	content := strings.TrimSpace(r.Form.Get("content"))
+	if sid == "" || model == "" || mid == "" || content == "" {
+		http.Error(w, "bad request", 400)
+		return
+	}
+	opts, err := parseOptionsForm(r.Form)
+	if err == nil {
+		err = opts.Validate()
+	}
+	if err != nil {
+		http.Error(w, "invalid options: "+err.Error(), 400)
+		return
+	}
+
+	hist := u.historyViews(sid)
+	at := -1
+	for i, v := range hist {
+		if v.ID == mid {
+			at = i
+			break
+		}
+	}
+	if at < 0 || hist[at].Role != string(types.RoleUser) {
+		http.Error(w, chat.ErrNotUserMessage.Error(), 400)
+		return
+	}
+
+	id := u.pending.put(pendingChat{Kind: pendingEdit, SessionID: sid, Model: model, Message: content, MessageID: mid, Options: opts})
+	views := append(hist[:at:at], MsgView{Role: "user", HTML: u.mdHTML(content)})
+	u.renderBranch(w, views, id)
+}
+
+// CheckoutPost switches to the branch through message_id and re-renders the history.
+// With "open" set (a search hit, possibly in another session) it redirects to the
+// message instead.
+func (u *UI) CheckoutPost(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	sid, mid := r.Form.Get("session_id"), r.Form.Get("message_id")
+	if _, err := u.chat.SwitchBranch(sid, mid); err != nil {
+		status := 500
+		if errors.Is(err, session.ErrNotFound) {
+			status = 404
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if r.Form.Get("open") != "" {
+		w.Header().Set("HX-Redirect", "/?s="+url.QueryEscape(sid)+"&m="+url.QueryEscape(mid)+"#m-"+mid)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	u.render(w, "history.html", u.historyViews(sid), http.StatusOK)
+}
+
+// renderBranch writes the given messages followed by a streaming placeholder.
+func (u *UI) renderBranch(w http.ResponseWriter, views []MsgView, streamID string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := u.tpl.ExecuteTemplate(w, "history.html", views); err != nil {
+		u.errTpl(w, err)
+		return
+	}
+	if err := u.tpl.ExecuteTemplate(w, "stream.html", streamVM{ID: streamID}); err != nil {
+		u.errTpl(w, err)
+	}
+}
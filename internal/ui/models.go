@@ -0,0 +1,99 @@
+package ui
+
+import (
+	"fmt"
+	"github.com/varsilias/zero-downtime/internal/models"
+	"sort"
+	"strings"
+)
+
+// modelGroup is one <optgroup> of the model dropdown.
+type modelGroup struct {
+	Label   string // family; empty when no model reports one (no <optgroup> then)
+	Options []modelOption
+}
+
+type modelOption struct {
+	Name  string
+	Label string // name, size and quantization
+	Title string // tooltip: parameter size, digest, backend
+}
+
+// modelGroups groups the dropdown by family, families in alphabetical order and models
+// without one last, under "other".
+func modelGroups(infos []models.ModelInfo) []modelGroup {
+	byFamily := map[string][]modelOption{}
+	for _, m := range infos {
+		byFamily[m.Family] = append(byFamily[m.Family], newModelOption(m))
+	}
+	families := make([]string, 0, len(byFamily))
+	for f := range byFamily {
+		if f != "" {
+			families = append(families, f)
+		}
+	}
+	sort.Strings(families)
+
+	out := make([]modelGroup, 0, len(byFamily))
+	for _, f := range families {
+		out = append(out, modelGroup{Label: f, Options: byFamily[f]})
+	}
+	if rest := byFamily[""]; len(rest) > 0 {
+		label := "other"
+		if len(families) == 0 {
+			label = ""
+		}
+		out = append(out, modelGroup{Label: label, Options: rest})
+	}
+	for _, g := range out {
+		sort.Slice(g.Options, func(i, j int) bool { return g.Options[i].Name < g.Options[j].Name })
+	}
+	return out
+}
+
+func newModelOption(m models.ModelInfo) modelOption {
+	label := []string{m.Name}
+	if m.Size > 0 {
+		label = append(label, humanSize(m.Size))
+	}
+	if m.Quantization != "" {
+		label = append(label, m.Quantization)
+	}
+	var title []string
+	if m.ParameterSize != "" {
+		title = append(title, m.ParameterSize+" parameters")
+	}
+	if m.Digest != "" {
+		title = append(title, "digest "+shortDigest(m.Digest))
+	}
+	if m.Backend != "" {
+		title = append(title, "backend "+m.Backend)
+	}
+	return modelOption{Name: m.Name, Label: strings.Join(label, " · "), Title: strings.Join(title, ", ")}
+}
+
+// humanSize formats bytes in decimal units, as `ollama list` does.
+func humanSize(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	v := float64(n) / float64(div)
+	if v < 10 {
+		return fmt.Sprintf("%.1f %cB", v, "kMGTPE"[exp])
+	}
+	return fmt.Sprintf("%.0f %cB", v, "kMGTPE"[exp])
+}
+
+func shortDigest(d string) string {
+	d = strings.TrimPrefix(d, "sha256:")
+	if len(d) > 12 {
+		d = d[:12]
+	}
+	return d
+}
@@ -1,13 +1,15 @@
 package ui
 
 import (
+	"fmt"
 	"github.com/go-chi/chi/v5"
 	"github.com/varsilias/zero-downtime/internal/buildinfo"
-	"github.com/varsilias/zero-downtime/internal/session"
+	"github.com/varsilias/zero-downtime/internal/chat"
+	"github.com/varsilias/zero-downtime/pkg/types"
 	"net/http"
-	"sort"
+	"net/url"
+	"strconv"
 	"strings"
-	"time"
 )
 
 type HomeData struct {
@@ -19,8 +21,24 @@ type HomeData struct {
 func RegisterRoutes(mux *chi.Mux, h *UI) {
 	mux.Get("/", h.Home)
 	mux.Post("/ui/chat", h.ChatPost)
+	mux.Get("/ui/chat/stream/{id}", h.ChatStream)
+	mux.Post("/ui/chat/regenerate", h.RegeneratePost)
+	mux.Post("/ui/chat/edit", h.EditPost)
+	mux.Post("/ui/session/checkout", h.CheckoutPost)
 	mux.Post("/ui/session/new", h.NewSession)
+	mux.Post("/ui/session/system", h.SystemPrompt)
+	mux.Post("/ui/session/pin", h.PinSession)
+	mux.Post("/ui/session/rename", h.RenameSession)
+	mux.Post("/ui/session/delete", h.DeleteSession)
+	mux.Post("/ui/session/import", h.ImportSession)
+	mux.Get("/ui/search", h.Search)
 	mux.Get("/ui/version-pill", h.VersionPill)
+	if h.ollama != nil {
+		mux.Get("/ui/admin", h.Admin)
+		mux.Get("/ui/admin/loaded", h.Loaded)
+		mux.Post("/ui/admin/unload", h.UnloadPost)
+		mux.Post("/ui/admin/warmup", h.WarmupPost)
+	}
 }
 
 // Home shows the chat UI. Optional session via query: /?s=<id>
@@ -33,32 +51,38 @@ func (u *UI) Home(w http.ResponseWriter, r *http.Request) {
 	// preload models
 	mods, _ := u.models.List(r.Context())
 
-	// history
-	msgs, _ := u.sessions.Get(sid)
-	hist := make([]MsgView, 0, len(msgs))
-	for _, m := range msgs {
-		hist = append(hist, MsgView{Role: string(m.Role), HTML: u.mdHTML(m.Content)})
+	// history (active branch); a link to a message on another branch shows that one,
+	// read-only until it is checked out
+	hist := u.historyViews(sid)
+	var viewing string
+	if mid := r.URL.Query().Get("m"); mid != "" {
+		if views, ok := u.otherBranch(sid, mid); ok {
+			hist, viewing = views, mid
+		}
 	}
 
-	// sessions list (best effort if memory store)
-	var sessions []session.Summary
-	if mem, ok := u.sessions.(*session.MemoryStore); ok {
-		sessions = mem.List()
-		sort.SliceStable(sessions, func(i, j int) bool { return sessions[i].Updated.After(sessions[j].Updated) })
-	}
+	system, _ := u.sessions.SystemPrompt(sid)
+	meta, _ := u.sessions.Meta(sid)
 
 	u.render(w, "chat.html", map[string]any{
-		"Models":    mods,
+		"Models":    modelGroups(mods),
 		"SessionID": sid,
 		"History":   hist,
-		"Sessions":  sessions,
+		"Viewing":   viewing,
+		"Trimmed":   meta.Trimmed,
+		"System":    newSystemVM(sid, system),
+		"Personas":  chat.Personas,
+		"Sessions":  u.sessionList(),
 		"Commit":    buildinfo.Commit,
 		"Version":   buildinfo.Version,
 		"BuiltAt":   buildinfo.BuiltAt,
+		"Backend":   u.backend(),
+		"Admin":     u.ollama != nil,
 	}, http.StatusOK)
 }
 
-// ChatPost returns *two fragments*: user bubble then assistant bubble.
+// ChatPost returns *two fragments*: the user bubble and an empty assistant bubble
+// that the browser fills in from /ui/chat/stream/{id}.
 func (u *UI) ChatPost(w http.ResponseWriter, r *http.Request) {
 	_ = r.ParseForm()
 	model := r.Form.Get("model")
@@ -71,6 +95,14 @@ func (u *UI) ChatPost(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "bad request", 400)
 		return
 	}
+	opts, err := parseOptionsForm(r.Form)
+	if err == nil {
+		err = opts.Validate()
+	}
+	if err != nil {
+		http.Error(w, "invalid options: "+err.Error(), 400)
+		return
+	}
 
 	// Optimistically render user bubble first
 	user := MsgView{Role: "user", HTML: u.mdHTML(msg)}
@@ -79,21 +111,114 @@ func (u *UI) ChatPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Then compute assistant reply via controller
-	reply, latency, err := u.chat.Chat(r.Context(), sid, model, msg)
-	if err != nil {
+	// Then hand the prompt to the stream endpoint; the reply is generated there
+	id := u.pending.put(pendingChat{SessionID: sid, Model: model, Message: msg, Options: opts})
+	if err := u.tpl.ExecuteTemplate(w, "stream.html", streamVM{ID: id}); err != nil {
+		u.errTpl(w, err)
+	}
+}
+
+// parseOptionsForm reads the optional generation fields of the composer. Empty fields
+// are left unset; stop sequences are comma-separated.
+func parseOptionsForm(f url.Values) (types.GenerateOptions, error) {
+	var o types.GenerateOptions
+	parseFloat := func(name string) (*float64, error) {
+		v := strings.TrimSpace(f.Get(name))
+		if v == "" {
+			return nil, nil
+		}
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a number", name)
+		}
+		return &n, nil
+	}
+	parseInt := func(name string) (*int, error) {
+		v := strings.TrimSpace(f.Get(name))
+		if v == "" {
+			return nil, nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be an integer", name)
+		}
+		return &n, nil
+	}
+
+	var err error
+	if o.Temperature, err = parseFloat("temperature"); err != nil {
+		return o, err
+	}
+	if o.TopP, err = parseFloat("top_p"); err != nil {
+		return o, err
+	}
+	if o.NumPredict, err = parseInt("num_predict"); err != nil {
+		return o, err
+	}
+	if o.Seed, err = parseInt("seed"); err != nil {
+		return o, err
+	}
+	for _, s := range strings.Split(f.Get("stop"), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			o.Stop = append(o.Stop, s)
+		}
+	}
+	return o, nil
+}
+
+type systemVM struct {
+	SessionID string
+	Prompt    string
+	Persona   string // name of the matching preset, if any
+}
+
+func newSystemVM(sessionID, prompt string) systemVM {
+	vm := systemVM{SessionID: sessionID, Prompt: prompt}
+	for _, p := range chat.Personas {
+		if p.Prompt == prompt {
+			vm.Persona = p.Name
+		}
+	}
+	return vm
+}
+
+// SystemPrompt sets the session system prompt from the custom textarea ("system") or
+// the composer's persona picker ("persona") and returns the refreshed system bar.
+func (u *UI) SystemPrompt(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	sid := r.Form.Get("session_id")
+	if sid == "" {
+		sid = "default"
+	}
+
+	prompt := strings.TrimSpace(r.Form.Get("system"))
+	if !r.Form.Has("system") {
+		if id := r.Form.Get("persona"); id != "" {
+			p, ok := chat.PersonaByID(id)
+			if !ok {
+				http.Error(w, "unknown persona", 400)
+				return
+			}
+			prompt = p.Prompt
+		}
+	}
+	if len(prompt) > chat.MaxSystemPrompt {
+		http.Error(w, "system prompt too long", 400)
+		return
+	}
+	if err := u.sessions.SetSystemPrompt(sid, prompt); err != nil {
 		http.Error(w, err.Error(), 500)
 		return
 	}
-	assistant := MsgView{Role: "assistant", HTML: u.mdHTML(reply.Content), Latency: latency.Milliseconds(), At: time.Now().Format(time.RFC822)}
-	_ = u.tpl.ExecuteTemplate(w, "message.html", assistant)
+	u.render(w, "system.html", newSystemVM(sid, prompt), http.StatusOK)
 }
 
 // NewSession creates a fresh session ID and redirects to /?s=...
 func (u *UI) NewSession(w http.ResponseWriter, r *http.Request) {
 	id := newID()
-	if mem, ok := u.sessions.(*session.MemoryStore); ok {
-		mem.Touch(id)
+	if err := u.sessions.Create(id); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
 	}
 	url := "/?s=" + id
 
@@ -112,6 +237,15 @@ type versionVM struct {
 	Version string
 	Commit  string
 	BuiltAt string
+	Backend *chat.BackendStatus // nil when the engine does not fail over
+}
+
+// backend is the engine's failover status for the version pill, or nil.
+func (u *UI) backend() *chat.BackendStatus {
+	if b, ok := u.chat.Backend(); ok {
+		return &b
+	}
+	return nil
 }
 
 func (u *UI) VersionPill(w http.ResponseWriter, r *http.Request) {
@@ -123,6 +257,7 @@ func (u *UI) VersionPill(w http.ResponseWriter, r *http.Request) {
 		Version: buildinfo.Version,
 		Commit:  buildinfo.Commit,
 		BuiltAt: buildinfo.BuiltAt,
+		Backend: u.backend(),
 	}
 	if err := u.tpl.ExecuteTemplate(w, "version-pill.html", data); err != nil {
 		u.errTpl(w, err)
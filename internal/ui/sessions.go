@@ -0,0 +1,151 @@
+package ui
+
+import (
+	"errors"
+	"github.com/varsilias/zero-downtime/internal/chat"
+	"github.com/varsilias/zero-downtime/internal/session"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// sessionList loads the sidebar entries; a failing store leaves the sidebar empty.
+func (u *UI) sessionList() []session.Summary {
+	sessions, err := u.sessions.List()
+	if err != nil {
+		u.log.Error("list sessions", "err", err)
+	}
+	return sessions
+}
+
+// currentSession is the session open in the page that sent an HTMX request.
+func currentSession(r *http.Request) string {
+	if cur, err := url.Parse(r.Header.Get("HX-Current-URL")); err == nil {
+		if sid := cur.Query().Get("s"); sid != "" {
+			return sid
+		}
+	}
+	return "default"
+}
+
+// renderSessions answers a sidebar action with the refreshed session list.
+func (u *UI) renderSessions(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, session.ErrSessionNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, session.ErrPinLimit) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	u.render(w, "sessions.html", map[string]any{
+		"Sessions":  u.sessionList(),
+		"SessionID": currentSession(r),
+	}, http.StatusOK)
+}
+
+// PinSession toggles whether a session is kept at the top of the sidebar.
+func (u *UI) PinSession(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	err := u.sessions.Pin(r.Form.Get("session_id"), r.Form.Get("pinned") == "true")
+	u.renderSessions(w, r, err)
+}
+
+// RenameSession sets a custom title; an empty title goes back to the derived one.
+func (u *UI) RenameSession(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	title := strings.TrimSpace(r.Form.Get("title"))
+	if len(title) > session.MaxTitle {
+		http.Error(w, "title too long", 400)
+		return
+	}
+	err := u.sessions.Rename(r.Form.Get("session_id"), title)
+	u.renderSessions(w, r, err)
+}
+
+// DeleteSession removes a session; deleting the open one navigates to a fresh chat.
+func (u *UI) DeleteSession(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	sid := r.Form.Get("session_id")
+	if err := u.sessions.Delete(sid); err != nil {
+		u.renderSessions(w, r, err)
+		return
+	}
+	if sid == currentSession(r) {
+		w.Header().Set("HX-Redirect", "/?s="+newID())
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	u.renderSessions(w, r, nil)
+}
+
+// ImportSession creates a session from an uploaded conversation file and opens it.
+func (u *UI) ImportSession(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, session.MaxImportBytes)
+	f, _, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "choose a conversation file (max 8 MiB)", 400)
+		return
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	conv, err := session.ParseConversation(data)
+	if err == nil && len(conv.System) > chat.MaxSystemPrompt {
+		err = errors.New("system prompt too long")
+	}
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	sid := newID()
+	if err := session.Import(u.sessions, sid, conv); err != nil {
+		status := 500
+		if errors.Is(err, session.ErrTooManyMessages) {
+			status = 413
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	http.Redirect(w, r, "/?s="+sid, http.StatusSeeOther)
+}
+
+// Search answers the sidebar search box with links to the matching messages.
+func (u *UI) Search(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	var hits []session.Hit
+	if len(session.ParseQuery(q)) > 0 {
+		var err error
+		if hits, err = u.sessions.Search(q, 20); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	u.render(w, "search.html", map[string]any{"Query": q, "Hits": hits}, http.StatusOK)
+}
+
+// otherBranch renders the branch through messageID read-only, without making it the
+// active one, when messageID is in the session but not on its active branch.
+func (u *UI) otherBranch(sid, messageID string) ([]MsgView, bool) {
+	active, _ := u.sessions.Get(sid)
+	if _, ok := session.Find(active, messageID); ok {
+		return nil, false
+	}
+	all, _ := u.sessions.Tree(sid)
+	if _, ok := session.Find(all, messageID); !ok {
+		return nil, false
+	}
+	views := u.branchViews(session.Branch(all, session.LatestLeaf(all, messageID)), all)
+	for i := range views {
+		views[i].ReadOnly, views[i].CanRegenerate = true, false
+	}
+	return views, true
+}
@@ -0,0 +1,136 @@
+package ui
+
+import (
+	"bytes"
+	"github.com/go-chi/chi/v5"
+	"github.com/varsilias/zero-downtime/internal/chat"
+	"github.com/varsilias/zero-downtime/pkg/types"
+	"github.com/varsilias/zero-downtime/pkg/utils"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// pendingTTL bounds how long a posted prompt waits for the browser to open its stream.
+const pendingTTL = 2 * time.Minute
+
+// pendingKind says which controller call a pending stream runs.
+type pendingKind int
+
+const (
+	pendingChatTurn pendingKind = iota
+	pendingRegenerate
+	pendingEdit
+)
+
+// pendingChat is a prompt accepted by ChatPost (or a regenerate/edit request) and not
+// yet picked up by ChatStream.
+type pendingChat struct {
+	Kind      pendingKind
+	SessionID string
+	Model     string
+	Message   string // prompt, or the edited content
+	MessageID string // message being edited
+	Options   types.GenerateOptions
+	created   time.Time
+}
+
+type pendingChats struct {
+	mu    sync.Mutex
+	items map[string]pendingChat
+}
+
+func newPendingChats() *pendingChats {
+	return &pendingChats{items: make(map[string]pendingChat)}
+}
+
+func (p *pendingChats) put(c pendingChat) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	for id, it := range p.items {
+		if now.Sub(it.created) > pendingTTL {
+			delete(p.items, id)
+		}
+	}
+	id := newID()
+	c.created = now
+	p.items[id] = c
+	return id
+}
+
+// take removes and returns the pending chat, so each stream can only be consumed once.
+func (p *pendingChats) take(id string) (pendingChat, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c, ok := p.items[id]
+	delete(p.items, id)
+	return c, ok
+}
+
+type streamVM struct {
+	ID string
+}
+
+// ChatStream GET /ui/chat/stream/{id} streams the assistant reply as SSE.
+// Events: "token" (JSON string), "done" ({html, latency_ms, dropped, replaces_prompt})
+// and "fail" ({error}). When replaces_prompt is set, html also re-renders the user bubble
+// in front of the placeholder.
+func (u *UI) ChatStream(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	p, ok := u.pending.take(id)
+	if !ok {
+		http.Error(w, "unknown stream", http.StatusNotFound)
+		return
+	}
+
+	es := utils.NewEventStream(w)
+	// the stream ID doubles as the generation ID, so the Stop button can cancel it
+	ctx := chat.WithGenerationID(r.Context(), id)
+	// position in the model's queue while the scheduler holds the request back (0 = started)
+	ctx = chat.WithQueueFunc(ctx, func(position int) {
+		_ = es.Send("queue", map[string]any{"position": position})
+	})
+	onToken := func(token string) error {
+		return es.Send("token", token)
+	}
+	var (
+		reply   types.Message
+		latency time.Duration
+		err     error
+	)
+	switch p.Kind {
+	case pendingRegenerate:
+		reply, latency, err = u.chat.Regenerate(ctx, p.SessionID, p.Model, p.Options, onToken)
+	case pendingEdit:
+		reply, latency, err = u.chat.Edit(ctx, p.SessionID, p.MessageID, p.Message, p.Model, p.Options, onToken)
+	default:
+		reply, latency, err = u.chat.ChatStream(ctx, p.SessionID, p.Model, p.Message, p.Options, onToken)
+	}
+	if err != nil {
+		u.log.Error("chat stream", "err", err)
+		_ = es.Send("fail", map[string]any{"error": err.Error()})
+		return
+	}
+
+	// re-render the prompt and the reply from the store so they carry IDs, sibling
+	// navigation and edit/regenerate controls
+	hist := u.historyViews(p.SessionID)
+	from := len(hist) - 1
+	if from > 0 && hist[from-1].Role == string(types.RoleUser) {
+		from--
+	}
+	if from >= 0 {
+		hist[len(hist)-1].Latency = latency.Milliseconds()
+		hist[len(hist)-1].At = reply.Timestamp.Format(time.RFC822)
+	}
+	var buf bytes.Buffer
+	if err := u.tpl.ExecuteTemplate(&buf, "history.html", hist[max(from, 0):]); err != nil {
+		u.log.Error("template execute", "err", err)
+	}
+	var dropped []int
+	if reply.Context != nil {
+		dropped = reply.Context.Dropped
+	}
+	_ = es.Send("done", map[string]any{"html": buf.String(), "latency_ms": latency.Milliseconds(), "dropped": dropped, "replaces_prompt": from < len(hist)-1})
+}
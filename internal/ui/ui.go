@@ -8,7 +8,9 @@ import (
 	"github.com/microcosm-cc/bluemonday"
 	"github.com/varsilias/zero-downtime/internal/chat"
 	"github.com/varsilias/zero-downtime/internal/models"
+	"github.com/varsilias/zero-downtime/internal/ollama"
 	"github.com/varsilias/zero-downtime/internal/session"
+	"github.com/varsilias/zero-downtime/pkg/types"
 	"github.com/yuin/goldmark"
 	highlighting "github.com/yuin/goldmark-highlighting/v2"
 	gmhtml "github.com/yuin/goldmark/renderer/html"
@@ -25,6 +27,8 @@ type UI struct {
 	models   models.Manager
 	sessions session.Store
 	md       goldmark.Markdown
+	pending  *pendingChats
+	ollama   ollama.API // nil unless EnableAdmin was called
 }
 
 func New(log *slog.Logger, c *chat.Controller, m models.Manager, s session.Store) (*UI, error) {
@@ -59,14 +63,28 @@ func New(log *slog.Logger, c *chat.Controller, m models.Manager, s session.Store
 		models:   m,
 		sessions: s,
 		md:       md,
+		pending:  newPendingChats(),
 	}, nil
 }
 
 type MsgView struct {
+	ID      string
+	Content string // raw Markdown, prefilled when editing
 	Role    string
 	HTML    template.HTML
 	Latency int64
 	At      string
+	Dropped bool               // left out of the context of the latest reply
+	Context *types.ContextInfo // how the history was trimmed for this reply
+	Stopped bool
+
+	// branching: position among the sibling versions of this message
+	SiblingPos    int // 1-based
+	SiblingCount  int
+	PrevSibling   string
+	NextSibling   string
+	CanRegenerate bool // last assistant reply of the active branch
+	ReadOnly      bool // on a branch shown without checking it out
 }
 
 func (u *UI) mdHTML(src string) template.HTML {
@@ -2,23 +2,30 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"expvar"
 	"flag"
 	"fmt"
 	"github.com/go-chi/chi/v5"
+	"github.com/redis/go-redis/v9"
 	"github.com/varsilias/zero-downtime/internal/api"
+	"github.com/varsilias/zero-downtime/internal/backend"
 	"github.com/varsilias/zero-downtime/internal/buildinfo"
 	"github.com/varsilias/zero-downtime/internal/chat"
 	"github.com/varsilias/zero-downtime/internal/logging"
 	"github.com/varsilias/zero-downtime/internal/middleware"
 	"github.com/varsilias/zero-downtime/internal/models"
 	"github.com/varsilias/zero-downtime/internal/ollama"
+	"github.com/varsilias/zero-downtime/internal/openai"
 	"github.com/varsilias/zero-downtime/internal/session"
 	"github.com/varsilias/zero-downtime/internal/ui"
+	"github.com/varsilias/zero-downtime/pkg/types"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -27,55 +34,205 @@ import (
 func main() {
 	addr := flag.String("addr", getEnv("ADDR", "8080"), "HTTP listen address")
 	level := flag.String("log-level", getEnv("LOG_LEVEL", "info"), "log level: debug|info|warn|error")
-	json := flag.Bool("log-json", getEnv("LOG_JSON", "false") == "true", "log as JSON")
-	ollamaURL := flag.String("ollama", getEnv("OLLAMA_BASE_URL", "http://localhost:11434"), "Ollama base URL")
+	logJSON := flag.Bool("log-json", getEnv("LOG_JSON", "false") == "true", "log as JSON")
+	ollamaURL := flag.String("ollama", getEnv("OLLAMA_BASE_URL", "http://localhost:11434"), "Ollama base URL; several (comma-separated) are load-balanced")
+	openaiURL := flag.String("openai", getEnv("OPENAI_BASE_URL", ""), "OpenAI-compatible base URL incl. /v1 (vLLM, llama.cpp, …); preferred over Ollama when reachable")
+	backendsFile := flag.String("backends", getEnv("BACKENDS_FILE", ""), "multi-backend routing config (JSON); overrides -openai/-ollama detection")
+	openaiKey := getEnv("OPENAI_API_KEY", "")
+	openaiHeaders := getEnv("OPENAI_HEADERS", "") // "Name=value ..." sent with every upstream request
+	storeKind := flag.String("session-store", getEnv("SESSION_STORE", "memory"), "session store: memory|sqlite|redis")
+	sqlitePath := flag.String("sqlite-path", getEnv("SESSION_SQLITE_PATH", "data/sessions.db"), "SQLite database file (session-store=sqlite)")
+	redisURL := flag.String("redis-url", getEnv("SESSION_REDIS_URL", "redis://localhost:6379/0"), "Redis URL (session-store=redis)")
+	redisPrefix := getEnv("SESSION_REDIS_PREFIX", "zd:")
+
+	// memory store limits (0 disables one); keep the default store inside the pod's memory limit
+	maxSessions, _ := strconv.Atoi(getEnv("SESSION_MAX_SESSIONS", "1000"))
+	maxMessages, _ := strconv.Atoi(getEnv("SESSION_MAX_MESSAGES", "500"))
+	idleTTL, _ := time.ParseDuration(getEnv("SESSION_IDLE_TTL", "24h"))
+	sweepInterval, _ := time.ParseDuration(getEnv("SESSION_SWEEP_INTERVAL", "1m"))
+
+	// admin: whether this replica runs model pull jobs (in memory, so only one replica should)
+	adminJobs := strings.ToLower(getEnv("ADMIN_JOBS", "true")) == "true"
 
 	// ollama read knobs
 	waitEnabled := strings.ToLower(getEnv("OLLAMA_WAIT", "true")) == "true"
 	waitTimeout, _ := time.ParseDuration(getEnv("OLLAMA_WAIT_TIMEOUT", "180s"))
 	waitInterval, _ := time.ParseDuration(getEnv("OLLAMA_WAIT_INTERVAL", "2s"))
+	// ollama client resilience: retries for idempotent calls, per-endpoint circuit breakers
+	retries, _ := strconv.Atoi(getEnv("OLLAMA_RETRIES", "2"))
+	retryBase, _ := time.ParseDuration(getEnv("OLLAMA_RETRY_BASE", "200ms"))
+	retryMax, _ := time.ParseDuration(getEnv("OLLAMA_RETRY_MAX", "2s"))
+	breakerFailures, _ := strconv.Atoi(getEnv("OLLAMA_BREAKER_FAILURES", "5"))
+	breakerTimeout, _ := time.ParseDuration(getEnv("OLLAMA_BREAKER_OPEN_TIMEOUT", "30s"))
+	balance := getEnv("OLLAMA_BALANCE", "affinity") // affinity|least-inflight, with several OLLAMA_BASE_URLs
+	poolCheckInterval, _ := time.ParseDuration(getEnv("OLLAMA_POOL_CHECK_INTERVAL", "10s"))
+	probeInterval, _ := time.ParseDuration(getEnv("OLLAMA_PROBE_INTERVAL", "5s"))                          // failover health check
 	waitModels := strings.Fields(getEnv("OLLAMA_WAIT_MODELS", "gemma3:270m smollm:135m deepseek-r1:1.5b")) // "llama3.2 mistral"
 
+	// context window knobs (token estimates; see chat.EstimateTokens)
+	ctxStrategy := getEnv("CONTEXT_STRATEGY", "sliding") // sliding|keep-first-last|summary
+	ctxBudget, _ := strconv.Atoi(getEnv("CONTEXT_BUDGET", "4096"))
+	ctxBudgets := getEnv("CONTEXT_BUDGETS", "gemma3:270m=8192 smollm:135m=1536 deepseek-r1:1.5b=4096") // "model=tokens ..."
+
+	// scheduler: per-model in-flight cap (0 = unlimited) and a bounded FIFO queue in front of the engine
+	maxInFlight, _ := strconv.Atoi(getEnv("MODEL_MAX_INFLIGHT", "0"))
+	inFlightPerModel := getEnv("MODEL_MAX_INFLIGHT_PER_MODEL", "") // "model=n ..."
+	queueMax, _ := strconv.Atoi(getEnv("MODEL_QUEUE_MAX", "16"))
+	queueTimeout, _ := time.ParseDuration(getEnv("MODEL_QUEUE_TIMEOUT", "60s"))
+
+	// per-model generation defaults as JSON, e.g. {"*":{"num_predict":512},"deepseek-r1:1.5b":{"temperature":0.6}}
+	modelOptions := getEnv("MODEL_OPTIONS", "")
+
+	// ollama residency: how long models stay loaded after a call, and which to load at startup
+	modelKeepAlive := getEnv("MODEL_KEEP_ALIVE", "")           // "*=30m gemma3:270m=-1 ..." (-1 = until unloaded)
+	warmupModels := strings.Fields(getEnv("MODEL_WARMUP", "")) // "gemma3:270m smollm:135m"
+
 	flag.Parse()
 
 	ollamaActive := false
 
-	logger := logging.New(*level, *json)
+	logger := logging.New(*level, *logJSON)
 	logger.Info("build", "version", buildinfo.Version, "commit", buildinfo.Commit, "built_at", buildinfo.BuiltAt)
 	logger.Info("Lord speak you server is listening", "port", *addr, "ollama", *ollamaURL)
 
-	// Dependencies (prefer Ollama if reachable; else fall back to echo)
+	// Dependencies (a backends routing file if given; else prefer a configured OpenAI-compatible upstream, then Ollama with runtime failover to echo)
+	resilience := ollama.Resilience{Retries: retries, RetryBase: retryBase, RetryMax: retryMax, Failures: breakerFailures, OpenTimeout: breakerTimeout}
+	keepAlive := ollama.KeepAlive{}
+	for model, v := range parseModelMap(modelKeepAlive) {
+		d, err := ollama.ParseKeepAlive(v)
+		if err != nil {
+			logger.Warn("invalid MODEL_KEEP_ALIVE entry; skipping", "model", model, "err", err)
+			continue
+		}
+		keepAlive[model] = d
+	}
 	var (
-		engine    chat.Engine
-		modelsMgr models.Manager
+		engine      chat.Engine
+		modelsMgr   models.Manager
+		closeEngine = func() error { return nil }
 	)
 
-	oc := ollama.NewClient(*ollamaURL, logger)
-	if waitEnabled {
-		logger.Info("waiting for Ollama", "timeout", waitTimeout.String(), "interval", waitInterval.String(), "models", waitModels)
-		ctxWait, cancel := context.WithTimeout(context.Background(), waitTimeout)
-		err := waitForOllama(ctxWait, oc, waitModels, waitInterval, logger)
-		cancel()
+	// a routing table over several backends replaces the single-backend detection
+	if *backendsFile != "" {
+		cfg, err := backend.Load(*backendsFile)
+		if err != nil {
+			logger.Error("backends config", "err", err)
+			os.Exit(1)
+		}
+		router, mgr, err := backend.Open(cfg, logger, resilience, keepAlive)
 		if err != nil {
-			logger.Warn("Ollama wait timed out; continuing with fallback", "err", err.Error())
+			logger.Error("backends config", "file", *backendsFile, "err", err)
+			os.Exit(1)
+		}
+		logger.Info("routing models across backends", "backends", cfg.Names(), "routes", len(cfg.Routes), "default", cfg.Default)
+		engine, modelsMgr = router, mgr
+	}
+
+	// an explicitly configured OpenAI-compatible upstream wins when it answers
+	if engine == nil && *openaiURL != "" {
+		oa := openai.NewClient(*openaiURL, openaiKey, parseModelMap(openaiHeaders), logger)
+		if err := oa.Ping(context.Background()); err == nil {
+			logger.Info("openai-compatible upstream reachable: enabling openai engine", "url", *openaiURL)
+			engine = chat.NewOpenAIEngine(oa)
+			modelsMgr = models.NewOpenAIManager(oa)
 		} else {
-			logger.Info("Ollama is ready (API + required models present)")
+			logger.Warn("openai-compatible upstream not reachable; trying ollama", "url", *openaiURL, "err", err)
 		}
 	}
 
-	if err := oc.Ping(context.Background()); err == nil {
-		logger.Info("ollama reachable: enabling ollama engine")
-		engine = chat.NewOllamaEngine(oc)
-		modelsMgr = models.NewOllamaManager(oc)
+	// only the ollama path builds a client, so no pool health checks run for another engine
+	var oc ollama.API
+	closeOllama := func() error { return nil }
+	if engine == nil {
+		if urls := strings.FieldsFunc(*ollamaURL, func(r rune) bool { return r == ',' || r == ' ' }); len(urls) > 1 {
+			b, err := ollama.ParseBalance(balance)
+			if err != nil {
+				logger.Warn("invalid OLLAMA_BALANCE; using affinity", "err", err)
+				b = ollama.BalanceAffinity
+			}
+			pool := ollama.NewPool(urls, logger, resilience, keepAlive, b, poolCheckInterval)
+			logger.Info("load-balancing across ollama instances", "urls", urls, "balance", b)
+			oc, closeOllama = pool, pool.Close
+		} else {
+			oc = ollama.NewClient(*ollamaURL, logger, resilience, keepAlive)
+		}
+		if waitEnabled {
+			logger.Info("waiting for Ollama", "timeout", waitTimeout.String(), "interval", waitInterval.String(), "models", waitModels)
+			ctxWait, cancel := context.WithTimeout(context.Background(), waitTimeout)
+			err := waitForOllama(ctxWait, oc, waitModels, waitInterval, logger)
+			cancel()
+			if err != nil {
+				logger.Warn("Ollama wait timed out; continuing with fallback", "err", err.Error())
+			} else {
+				logger.Info("Ollama is ready (API + required models present)")
+			}
+		}
+		if len(warmupModels) > 0 {
+			// load in the background so a slow CPU load does not hold up readiness
+			go warmUp(oc, warmupModels, logger)
+		}
+
+		// serve from Ollama while it answers and from echo while it does not, re-probing in the background
+		fo := chat.NewFailover("ollama", chat.NewOllamaEngine(oc), "echo", chat.NewEchoEngine(30*time.Millisecond), oc.Ping, probeInterval, logger)
+		closeEngine = fo.Close
+		engine = fo
+		modelsMgr = models.NewFailoverManager(models.NewOllamaManager(oc), models.NewStaticManager([]string{"llama2", "mistral", "phi3"}), fo.PrimaryUp)
 		ollamaActive = true
-	} else {
-		logger.Warn("ollama not reachable; falling back to echo engine", "err", err)
-		modelsMgr = models.NewStaticManager([]string{"llama2", "mistral", "phi3"})
-		engine = chat.NewEchoEngine(30 * time.Millisecond)
 	}
 
-	sessionStore := session.NewMemoryStore()
-	chatCtrl := chat.NewController(logger, engine, sessionStore)
+	strategy, err := chat.ParseStrategy(ctxStrategy)
+	if err != nil {
+		logger.Warn("invalid CONTEXT_STRATEGY; using sliding window", "err", err)
+		strategy = chat.StrategySliding
+	}
+	budgets := map[string]int{}
+	for model, v := range parseModelMap(ctxBudgets) {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			logger.Warn("invalid CONTEXT_BUDGETS entry", "model", model, "value", v)
+			continue
+		}
+		budgets[model] = n
+	}
+
+	defaults := types.ModelOptions{}
+	if modelOptions != "" {
+		if err := json.Unmarshal([]byte(modelOptions), &defaults); err != nil {
+			logger.Error("invalid MODEL_OPTIONS", "err", err)
+			os.Exit(1)
+		}
+		if err := defaults.Validate(); err != nil {
+			logger.Error("invalid MODEL_OPTIONS", "err", err)
+			os.Exit(1)
+		}
+	}
+
+	limits := session.MemoryLimits{MaxSessions: maxSessions, MaxMessages: maxMessages, IdleTTL: idleTTL, Sweep: sweepInterval}
+	sessionStore, closeStore, err := newSessionStore(*storeKind, *sqlitePath, *redisURL, redisPrefix, limits)
+	if err != nil {
+		logger.Error("session store init", "store", *storeKind, "err", err)
+		os.Exit(1)
+	}
+	logger.Info("session store", "store", *storeKind)
+	schedLimits := chat.SchedulerLimits{MaxInFlight: maxInFlight, PerModel: map[string]int{}, MaxQueue: queueMax, QueueTimeout: queueTimeout}
+	for model, v := range parseModelMap(inFlightPerModel) {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			logger.Warn("invalid MODEL_MAX_INFLIGHT_PER_MODEL entry; skipping", "model", model, "value", v)
+			continue
+		}
+		schedLimits.PerModel[model] = n
+	}
+	if ce, ok := engine.(chat.ChatEngine); ok && (schedLimits.MaxInFlight > 0 || len(schedLimits.PerModel) > 0) {
+		sched := chat.NewScheduler(ce, schedLimits)
+		expvar.Publish("model_queues", expvar.Func(func() any { return sched.Stats() }))
+		logger.Info("scheduling generations", "max_in_flight", schedLimits.MaxInFlight, "per_model", schedLimits.PerModel, "queue", schedLimits.MaxQueue, "queue_timeout", schedLimits.QueueTimeout.String())
+		engine = sched
+	}
+
+	// after the scheduler wrap, so summaries queue behind the same per-model limits as chats
+	window := chat.NewContextBuilder(logger, chat.WindowConfig{Strategy: strategy, DefaultBudget: ctxBudget, Budgets: budgets}, engine)
+	chatCtrl := chat.NewController(logger, engine, sessionStore, window, defaults)
 
 	uih, err := ui.New(logger, chatCtrl, modelsMgr, sessionStore)
 	if err != nil {
@@ -85,11 +242,13 @@ func main() {
 
 	h := api.NewHandlers(logger, chatCtrl, modelsMgr, sessionStore)
 	if ollamaActive {
-		h.Admin = api.NewAdmin(oc)
+		h.Admin = api.NewAdmin(oc, adminJobs)
+		uih.EnableAdmin(oc)
 	}
 	mux := chi.NewRouter()
 
 	mux.Handle("/static/*", http.StripPrefix("/static/", http.FileServer(http.Dir("web/static"))))
+	mux.Handle("/debug/vars", expvar.Handler())
 
 	ui.RegisterRoutes(mux, uih)
 	api.RegisterRoutes(mux, h)
@@ -106,7 +265,7 @@ func main() {
 		ReadTimeout:       15 * time.Second,
 		ReadHeaderTimeout: 15 * time.Second,
 		//WriteTimeout:      60 * time.Second,
-		WriteTimeout: 5 * time.Minute, // using this to allow more time for request that take much time like pulling a model from ollama registry
+		WriteTimeout: 5 * time.Minute, // long SSE chat streams on CPU; model pulls run as background jobs (see /admin/jobs)
 		IdleTimeout:  120 * time.Second,
 	}
 
@@ -134,9 +293,49 @@ func main() {
 	} else {
 		logger.Info("server stopped")
 	}
+	if err := closeEngine(); err != nil {
+		logger.Error("engine close", "err", err)
+	}
+	if err := closeOllama(); err != nil {
+		logger.Error("ollama pool close", "err", err)
+	}
+	if err := closeStore(); err != nil {
+		logger.Error("session store close", "err", err)
+	}
+}
+
+// newSessionStore builds the configured session.Store; closeFn releases it on shutdown.
+func newSessionStore(kind, sqlitePath, redisURL, redisPrefix string, limits session.MemoryLimits) (store session.Store, closeFn func() error, err error) {
+	switch kind {
+	case "", "memory":
+		s := session.NewMemoryStore(limits)
+		return s, s.Close, nil
+	case "sqlite":
+		s, err := session.NewSQLiteStore(sqlitePath)
+		if err != nil {
+			return nil, nil, err
+		}
+		return s, s.Close, nil
+	case "redis":
+		opts, err := redis.ParseURL(redisURL)
+		if err != nil {
+			return nil, nil, err
+		}
+		rdb := redis.NewClient(opts)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := rdb.Ping(ctx).Err(); err != nil {
+			rdb.Close()
+			return nil, nil, fmt.Errorf("redis ping: %w", err)
+		}
+		s := session.NewRedisStore(rdb, redisPrefix)
+		return s, s.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown session store %q", kind)
+	}
 }
 
-func waitForOllama(ctx context.Context, oc *ollama.Client, models []string, interval time.Duration, log *slog.Logger) error {
+func waitForOllama(ctx context.Context, oc ollama.API, models []string, interval time.Duration, log *slog.Logger) error {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
@@ -185,6 +384,33 @@ func waitForOllama(ctx context.Context, oc *ollama.Client, models []string, inte
 	}
 }
 
+// warmUp loads models into Ollama one by one; a failed model just loads on first use.
+func warmUp(oc ollama.API, models []string, log *slog.Logger) {
+	for _, m := range models {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		took, err := oc.Load(ctx, m)
+		cancel()
+		if err != nil {
+			log.Warn("model warm-up failed", "model", m, "err", err)
+			continue
+		}
+		log.Info("model warmed up", "model", m, "took", took.Round(time.Millisecond))
+	}
+}
+
+// parseModelMap parses space-separated "model=value" pairs, e.g. "gemma3:270m=2048 smollm:135m=1536".
+func parseModelMap(s string) map[string]string {
+	out := map[string]string{}
+	for _, f := range strings.Fields(s) {
+		k, v, ok := strings.Cut(f, "=")
+		if !ok || k == "" {
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
 func getEnv(key, def string) string {
 	v := os.Getenv(key)
 	if v != "" {
@@ -5,12 +5,27 @@ import "time"
 type Role string
 
 const (
+	RoleSystem    Role = "system"
 	RoleUser      Role = "user"
 	RoleAssistant Role = "assistant"
 )
 
 type Message struct {
-	Role      Role      `json:"role"`
-	Content   string    `json:"content"`
-	Timestamp time.Time `json:"timestamp"`
+	ID        string       `json:"id,omitempty"`
+	ParentID  string       `json:"parent_id,omitempty"` // empty for the first message of a branch tree
+	Role      Role         `json:"role"`
+	Content   string       `json:"content"`
+	Timestamp time.Time    `json:"timestamp"`
+	Context   *ContextInfo `json:"context,omitempty"`    // set on assistant replies when history was trimmed
+	Stopped   bool         `json:"stopped,omitempty"`    // reply was cut short by the user; Content is partial
+	Model     string       `json:"model,omitempty"`      // assistant replies: model that produced it
+	LatencyMS int64        `json:"latency_ms,omitempty"` // assistant replies: generation time
+}
+
+// ContextInfo records how much of the history was sent to produce a reply.
+type ContextInfo struct {
+	Dropped    []int `json:"dropped,omitempty"` // indexes of history messages left out
+	Summarized bool  `json:"summarized,omitempty"`
+	Tokens     int   `json:"tokens"`
+	Budget     int   `json:"budget"`
 }
@@ -0,0 +1,91 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GenerateOptions are per-request sampling knobs. They are sent as Ollama's "options"
+// object, so the JSON names follow Ollama. Nil fields keep the model's own defaults.
+type GenerateOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	NumPredict  *int     `json:"num_predict,omitempty"`
+	Seed        *int     `json:"seed,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+}
+
+const (
+	maxNumPredict = 32768
+	maxStops      = 8
+	maxStopLen    = 64
+)
+
+// Validate checks every option that is set and reports the first one out of range.
+func (o GenerateOptions) Validate() error {
+	if o.Temperature != nil && (*o.Temperature < 0 || *o.Temperature > 2) {
+		return fmt.Errorf("temperature must be between 0 and 2")
+	}
+	if o.TopP != nil && (*o.TopP <= 0 || *o.TopP > 1) {
+		return fmt.Errorf("top_p must be greater than 0 and at most 1")
+	}
+	// -1 generates until the model stops, -2 until the context is full
+	if o.NumPredict != nil && (*o.NumPredict < -2 || *o.NumPredict == 0 || *o.NumPredict > maxNumPredict) {
+		return fmt.Errorf("num_predict must be -1, -2 or between 1 and %d", maxNumPredict)
+	}
+	if o.Seed != nil && *o.Seed < 0 {
+		return fmt.Errorf("seed must not be negative")
+	}
+	if len(o.Stop) > maxStops {
+		return fmt.Errorf("at most %d stop sequences are allowed", maxStops)
+	}
+	for _, s := range o.Stop {
+		if strings.TrimSpace(s) == "" || len(s) > maxStopLen {
+			return fmt.Errorf("stop sequences must be non-empty and at most %d bytes", maxStopLen)
+		}
+	}
+	return nil
+}
+
+// IsZero reports whether no option is set.
+func (o GenerateOptions) IsZero() bool {
+	return o.Temperature == nil && o.TopP == nil && o.NumPredict == nil && o.Seed == nil && len(o.Stop) == 0
+}
+
+// Merge returns o with every option set in over taking precedence.
+func (o GenerateOptions) Merge(over GenerateOptions) GenerateOptions {
+	if over.Temperature != nil {
+		o.Temperature = over.Temperature
+	}
+	if over.TopP != nil {
+		o.TopP = over.TopP
+	}
+	if over.NumPredict != nil {
+		o.NumPredict = over.NumPredict
+	}
+	if over.Seed != nil {
+		o.Seed = over.Seed
+	}
+	if len(over.Stop) > 0 {
+		o.Stop = over.Stop
+	}
+	return o
+}
+
+// ModelOptions holds configured defaults keyed by model name; the "*" entry applies to every model.
+type ModelOptions map[string]GenerateOptions
+
+// For returns the defaults for model: the "*" entry overlaid with the model's own entry.
+func (m ModelOptions) For(model string) GenerateOptions {
+	return m["*"].Merge(m[model])
+}
+
+// Validate checks every entry.
+func (m ModelOptions) Validate() error {
+	for model, o := range m {
+		if err := o.Validate(); err != nil {
+			return fmt.Errorf("%s: %w", model, err)
+		}
+	}
+	return nil
+}
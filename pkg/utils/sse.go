@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// EventStream writes Server-Sent Events, flushing after every event.
+type EventStream struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+// NewEventStream sets the SSE headers and sends the 200 status line.
+func NewEventStream(w http.ResponseWriter) *EventStream {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // keep nginx ingress from buffering the stream
+	w.WriteHeader(http.StatusOK)
+	s := &EventStream{w: w, rc: http.NewResponseController(w)}
+	_ = s.rc.Flush()
+	return s
+}
+
+// Send writes v as JSON in a single event. An empty event name sends an unnamed event.
+func (s *EventStream) Send(event string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.SendRaw(event, string(b))
+}
+
+// SendRaw writes data verbatim; it must not contain newlines.
+func (s *EventStream) SendRaw(event, data string) error {
+	if event != "" {
+		if _, err := fmt.Fprintf(s.w, "event: %s\n", event); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(s.w, "data: %s\n\n", data); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}